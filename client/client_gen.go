@@ -0,0 +1,200 @@
+// Code generated by cmd/openapi from the endpoints of package openapi. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	creatematch "darts-counter/cmd/server/http/createMatch"
+	createplayer "darts-counter/cmd/server/http/createPlayer"
+	editthrow "darts-counter/cmd/server/http/editThrow"
+	getmatch "darts-counter/cmd/server/http/getMatch"
+	headtohead "darts-counter/cmd/server/http/headToHead"
+	"darts-counter/cmd/server/http/leaderboard"
+	matchstats "darts-counter/cmd/server/http/matchStats"
+	playerstats "darts-counter/cmd/server/http/playerStats"
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	"darts-counter/cmd/server/http/rating"
+	undothrow "darts-counter/cmd/server/http/undoThrow"
+	updateplayer "darts-counter/cmd/server/http/updatePlayer"
+	"darts-counter/models"
+	"net/url"
+	"strconv"
+)
+
+// ListPlayers lists all players.
+//
+// GET /api/v1/players
+func (c *Client) ListPlayers(ctx context.Context) ([]*models.Player, error) {
+	var out []*models.Player
+	if err := c.do(ctx, "GET", "/api/v1/players", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreatePlayer creates a player.
+//
+// POST /api/v1/players
+func (c *Client) CreatePlayer(ctx context.Context, body *createplayer.Request) (*models.Player, error) {
+	var out models.Player
+	if err := c.do(ctx, "POST", "/api/v1/players", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdatePlayer renames a player, the ID of the body is ignored.
+//
+// PATCH /api/v1/players/{id}
+func (c *Client) UpdatePlayer(ctx context.Context, id string, body *updateplayer.Request) (*models.Player, error) {
+	var out models.Player
+	if err := c.do(ctx, "PATCH", "/api/v1/players/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeletePlayer deletes a player with its throws, match seats, statistics and rating history. The matches it played are kept.
+//
+// DELETE /api/v1/players/{id}
+func (c *Client) DeletePlayer(ctx context.Context, id string) (map[string]string, error) {
+	var out map[string]string
+	if err := c.do(ctx, "DELETE", "/api/v1/players/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PlayerStatistics returns the lifetime statistics of a player.
+//
+// GET /api/v1/players/{id}/statistics
+func (c *Client) PlayerStatistics(ctx context.Context, id string) (*playerstats.Response, error) {
+	var out playerstats.Response
+	if err := c.do(ctx, "GET", "/api/v1/players/"+url.PathEscape(id)+"/statistics", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Rating returns the rating of a player and its history.
+//
+// GET /api/v1/players/{id}/rating
+func (c *Client) Rating(ctx context.Context, id string) (*rating.Response, error) {
+	var out rating.Response
+	if err := c.do(ctx, "GET", "/api/v1/players/"+url.PathEscape(id)+"/rating", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// HeadToHead returns the record between two players.
+//
+// GET /api/v1/players/{id}/head-to-head/{opponentId}
+func (c *Client) HeadToHead(ctx context.Context, id string, opponentId string) (*headtohead.Response, error) {
+	var out headtohead.Response
+	if err := c.do(ctx, "GET", "/api/v1/players/"+url.PathEscape(id)+"/head-to-head/"+url.PathEscape(opponentId), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Leaderboard ranks the players by a metric.
+//
+// GET /api/v1/leaderboard
+//
+// The query may set metric, window, minMatches.
+func (c *Client) Leaderboard(ctx context.Context, query url.Values) (*leaderboard.Response, error) {
+	var out leaderboard.Response
+	if err := c.do(ctx, "GET", "/api/v1/leaderboard", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListMatches lists all matches.
+//
+// GET /api/v1/matches
+func (c *Client) ListMatches(ctx context.Context) ([]*models.Match, error) {
+	var out []*models.Match
+	if err := c.do(ctx, "GET", "/api/v1/matches", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateMatch creates a match, settings left out take the server defaults.
+//
+// POST /api/v1/matches
+func (c *Client) CreateMatch(ctx context.Context, body *creatematch.Request) (*models.Match, error) {
+	var out models.Match
+	if err := c.do(ctx, "POST", "/api/v1/matches", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMatch returns a match with its throw history.
+//
+// GET /api/v1/matches/{id}
+func (c *Client) GetMatch(ctx context.Context, id string) (*getmatch.Response, error) {
+	var out getmatch.Response
+	if err := c.do(ctx, "GET", "/api/v1/matches/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteMatch deletes a match.
+//
+// DELETE /api/v1/matches/{id}
+func (c *Client) DeleteMatch(ctx context.Context, id string) (map[string]string, error) {
+	var out map[string]string
+	if err := c.do(ctx, "DELETE", "/api/v1/matches/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MatchStatistics returns the X01 metrics of every player in a match.
+//
+// GET /api/v1/matches/{id}/statistics
+func (c *Client) MatchStatistics(ctx context.Context, id string) (*matchstats.Response, error) {
+	var out matchstats.Response
+	if err := c.do(ctx, "GET", "/api/v1/matches/"+url.PathEscape(id)+"/statistics", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlayerThrow records a throw. A stale version is answered with 409 and the current match as details.
+//
+// POST /api/v1/matches/{id}/throws
+func (c *Client) PlayerThrow(ctx context.Context, id string, body *playerthrow.Request) (*playerthrow.Response, error) {
+	var out playerthrow.Response
+	if err := c.do(ctx, "POST", "/api/v1/matches/"+url.PathEscape(id)+"/throws", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UndoThrow removes the latest throw of a match. A stale version is answered with 409 and the current match as details.
+//
+// DELETE /api/v1/matches/{id}/throws/last
+func (c *Client) UndoThrow(ctx context.Context, id string, body *undothrow.Request) (*getmatch.Response, error) {
+	var out getmatch.Response
+	if err := c.do(ctx, "DELETE", "/api/v1/matches/"+url.PathEscape(id)+"/throws/last", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EditThrow corrects a recorded throw and recomputes the match. A stale version is answered with 409 and the current match as details.
+//
+// PUT /api/v1/matches/{id}/throws/{throwId}
+func (c *Client) EditThrow(ctx context.Context, id string, throwId int64, body *editthrow.Request) (*getmatch.Response, error) {
+	var out getmatch.Response
+	if err := c.do(ctx, "PUT", "/api/v1/matches/"+url.PathEscape(id)+"/throws/"+strconv.FormatInt(throwId, 10), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"darts-counter/cmd/server/http/openapi"
+)
+
+func TestGenerated_UpToDate(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		gen  func() ([]byte, error)
+	}{
+		{"../api/openapi.json", openapi.JSON},
+		{"client_gen.go", func() ([]byte, error) { return openapi.Client("client") }},
+	} {
+		want, err := tc.gen()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is outdated, run go generate ./client", tc.path)
+		}
+	}
+}
+
+func TestClient_DecodesErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/matches/m1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":"not_found","message":"match not found"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).GetMatch(t.Context(), "m1")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+// Package client is a Go client of the darts-counter API. The methods of Client are generated from
+// the endpoints the OpenAPI document of the server describes, see api/openapi.json. It is tested against
+// the routes of the server in cmd/server.
+package client
+
+//go:generate go run darts-counter/cmd/openapi -spec ../api/openapi.json -client client_gen.go
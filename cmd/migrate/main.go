@@ -0,0 +1,81 @@
+// Command migrate shows, applies and rolls back schema migrations of a database.
+//
+// Usage:
+//
+//	migrate [-db darts.db|postgres://...] status|up|down
+//
+// up applies every pending migration, down rolls back the most recently applied group.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"darts-counter/storage"
+)
+
+func main() {
+	db := flag.String("db", "darts.db", "path of the sqlite database or a postgres:// URL")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-db path|url] status|up|down\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bunDB, err := storage.OpenDB(*db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer bunDB.Close()
+
+	ctx := context.Background()
+	migrator := storage.NewMigrator(bunDB)
+	if err := migrator.Init(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "status":
+		ms, err := migrator.MigrationsWithStatus(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range ms {
+			status := "pending"
+			if m.IsApplied() {
+				status = fmt.Sprintf("applied %s (group %d)", m.MigratedAt.Format("2006-01-02 15:04:05"), m.GroupID)
+			}
+			fmt.Printf("%-22s %-50s %s\n", m.Name, m.Comment, status)
+		}
+	case "up":
+		group, err := migrator.Migrate(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if group.IsZero() {
+			fmt.Println("no pending migrations")
+			return
+		}
+		fmt.Printf("applied %s\n", group)
+	case "down":
+		group, err := migrator.Rollback(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if group.IsZero() {
+			fmt.Println("no migrations to roll back")
+			return
+		}
+		fmt.Printf("rolled back %s\n", group)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,42 @@
+// Command openapi writes the OpenAPI document of the server and the methods of the Go client generated from it.
+//
+// Usage:
+//
+//	openapi [-spec api/openapi.json] [-client client/client_gen.go] [-package client]
+//
+// It is run by go generate in package client.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"darts-counter/cmd/server/http/openapi"
+)
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.json", "file the OpenAPI document is written to, empty to skip it")
+	clientPath := flag.String("client", "client/client_gen.go", "file the client methods are written to, empty to skip them")
+	pkg := flag.String("package", "client", "package of the client")
+	flag.Parse()
+
+	if *specPath != "" {
+		spec, err := openapi.JSON()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*specPath, spec, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *clientPath != "" {
+		src, err := openapi.Client(*pkg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*clientPath, src, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
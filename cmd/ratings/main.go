@@ -0,0 +1,21 @@
+// Command ratings rebuilds all player ratings from the finished matches in a database.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"darts-counter/storage"
+)
+
+func main() {
+	db := flag.String("db", "darts.db", "path of the sqlite database or a postgres:// URL")
+	flag.Parse()
+
+	store := storage.NewStorage(*db)
+	if err := store.RecomputeRatings(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("ratings recomputed")
+}
@@ -0,0 +1,360 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	creatematch "darts-counter/cmd/server/http/createMatch"
+	"darts-counter/models"
+	"darts-counter/storage"
+)
+
+// EnvPrefix prefixes the environment variables of every setting, e.g. DARTS_ADDR for addr.
+const EnvPrefix = "DARTS_"
+
+// Config is the configuration of the server.
+type Config struct {
+	// DB is the path of the SQLite database file or a postgres:// URL.
+	DB string `yaml:"db"`
+	// Memory keeps all data in memory instead of DB, it is lost when the server stops.
+	Memory bool `yaml:"memory"`
+	// Addr is the address the server listens on.
+	Addr string `yaml:"addr"`
+	// Assets is the directory files are streamed from.
+	Assets string `yaml:"assets"`
+	// CORSOrigins are the origins allowed to call the API from a browser, "*" allows every origin.
+	CORSOrigins []string `yaml:"cors_origins"`
+	// LogLevel is the minimum level logged: debug, info, warn or error.
+	LogLevel string `yaml:"log_level"`
+	// Game holds the settings of matches created without them.
+	Game Game `yaml:"game"`
+}
+
+// Game holds the default settings of new matches.
+type Game struct {
+	// Type is the game type: x01, cricket or cut-throat-cricket.
+	Type    string `yaml:"type"`
+	StartAt int    `yaml:"start_at"`
+	// StartMode and EndMode are the In and Out modes: straight, double or master. Both default to straight,
+	// which is what a match request leaving them out has always been played with.
+	StartMode string `yaml:"start_mode"`
+	EndMode   string `yaml:"end_mode"`
+	// Legs is the number of legs needed to win a set, Sets the number of sets needed to win the match.
+	Legs int `yaml:"legs"`
+	Sets int `yaml:"sets"`
+}
+
+var gameTypes = map[string]models.GameType{
+	"x01":                models.X01,
+	"cricket":            models.Cricket,
+	"cut-throat-cricket": models.CutThroatCricket,
+}
+
+var ioModes = map[string]models.IO{
+	"straight": models.Straight,
+	"double":   models.Double,
+	"master":   models.Master,
+}
+
+// Default returns the configuration used for everything that is not configured.
+func Default() *Config {
+	return &Config{
+		DB:          "darts.db",
+		Addr:        ":8080",
+		Assets:      "./assets",
+		CORSOrigins: []string{"*"},
+		LogLevel:    "info",
+		Game: Game{
+			Type:      "x01",
+			StartAt:   501,
+			StartMode: "straight",
+			EndMode:   "straight",
+			Legs:      1,
+			Sets:      1,
+		},
+	}
+}
+
+// setting is a configuration value that can be set by a flag and an environment variable.
+type setting struct {
+	name  string
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, value string) error
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.name, "-", "_"))
+}
+
+var settings = []setting{
+	{
+		"db", "path of the sqlite database or a postgres:// URL",
+		func(c *Config) string { return c.DB },
+		func(c *Config, v string) error { c.DB = v; return nil },
+	},
+	{
+		"memory", "keep all data in memory instead of a database, it is lost when the server stops",
+		func(c *Config) string { return strconv.FormatBool(c.Memory) },
+		func(c *Config, v string) error { return parseBool(v, &c.Memory) },
+	},
+	{
+		"addr", "address to listen on",
+		func(c *Config) string { return c.Addr },
+		func(c *Config, v string) error { c.Addr = v; return nil },
+	},
+	{
+		"assets", "directory files are streamed from",
+		func(c *Config) string { return c.Assets },
+		func(c *Config, v string) error { c.Assets = v; return nil },
+	},
+	{
+		"cors-origins", `comma separated origins allowed to call the API, "*" allows every origin`,
+		func(c *Config) string { return strings.Join(c.CORSOrigins, ",") },
+		func(c *Config, v string) error { c.CORSOrigins = splitList(v); return nil },
+	},
+	{
+		"log-level", "minimum level logged: debug, info, warn or error",
+		func(c *Config) string { return c.LogLevel },
+		func(c *Config, v string) error { c.LogLevel = v; return nil },
+	},
+	{
+		"game-type", "default game type: x01, cricket or cut-throat-cricket",
+		func(c *Config) string { return c.Game.Type },
+		func(c *Config, v string) error { c.Game.Type = v; return nil },
+	},
+	{
+		"start-at", "default start score of x01 matches",
+		func(c *Config) string { return strconv.Itoa(c.Game.StartAt) },
+		func(c *Config, v string) error { return parseInt(v, &c.Game.StartAt) },
+	},
+	{
+		"start-mode", "default in mode: straight, double or master",
+		func(c *Config) string { return c.Game.StartMode },
+		func(c *Config, v string) error { c.Game.StartMode = v; return nil },
+	},
+	{
+		"end-mode", "default out mode: straight, double or master",
+		func(c *Config) string { return c.Game.EndMode },
+		func(c *Config, v string) error { c.Game.EndMode = v; return nil },
+	},
+	{
+		"legs", "default number of legs needed to win a set",
+		func(c *Config) string { return strconv.Itoa(c.Game.Legs) },
+		func(c *Config, v string) error { return parseInt(v, &c.Game.Legs) },
+	},
+	{
+		"sets", "default number of sets needed to win the match",
+		func(c *Config) string { return strconv.Itoa(c.Game.Sets) },
+		func(c *Config, v string) error { return parseInt(v, &c.Game.Sets) },
+	},
+}
+
+// Load builds the configuration of the server from, in increasing precedence, the defaults,
+// the YAML file given by -config or DARTS_CONFIG, the DARTS_* environment variables and the flags in args.
+// It returns flag.ErrHelp if args ask for the usage, which is printed then.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(EnvPrefix+"CONFIG"), "YAML configuration file (env "+EnvPrefix+"CONFIG)")
+
+	// flags are applied after the file and the environment, in the order they were given
+	var fromFlags []func(c *Config) error
+	defaults := Default()
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env())
+		set := func(v string) error {
+			// parse values right away so that flag reports which one is wrong
+			if err := s.set(Default(), v); err != nil {
+				return err
+			}
+			fromFlags = append(fromFlags, func(c *Config) error { return s.set(c, v) })
+			return nil
+		}
+		if s.name == "memory" {
+			fs.BoolFunc(s.name, usage, set)
+			continue
+		}
+		fs.Func(s.name, fmt.Sprintf("%s (default %q)", usage, s.get(defaults)), set)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env()); v != "" {
+			if err := s.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env(), err)
+			}
+		}
+	}
+	for _, set := range fromFlags {
+		if err := set(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	// unknown keys are most likely typos
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting of the configuration.
+func (c *Config) Validate() error {
+	var errs []error
+	if !c.Memory && c.DB == "" {
+		errs = append(errs, errors.New("db: must be set unless memory is"))
+	}
+	if c.DB != "" && storage.IsPostgres(c.DB) {
+		if _, err := url.Parse(c.DB); err != nil {
+			errs = append(errs, fmt.Errorf("db: %w", err))
+		}
+	}
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %w", err))
+	}
+	if c.Assets == "" {
+		errs = append(errs, errors.New("assets: must be set"))
+	} else if fi, err := os.Stat(c.Assets); err == nil && !fi.IsDir() {
+		errs = append(errs, fmt.Errorf("assets: %s is not a directory", c.Assets))
+	}
+	for _, origin := range c.CORSOrigins {
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors_origins: %w", err))
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %w", err))
+	}
+
+	gameType, ok := gameTypes[c.Game.Type]
+	if !ok {
+		errs = append(errs, fmt.Errorf("game.type: unknown game type %q", c.Game.Type))
+	}
+	if gameType == models.X01 && c.Game.StartAt < 1 {
+		errs = append(errs, fmt.Errorf("game.start_at: must be positive, got %d", c.Game.StartAt))
+	}
+	if _, ok := ioModes[c.Game.StartMode]; !ok {
+		errs = append(errs, fmt.Errorf("game.start_mode: unknown mode %q", c.Game.StartMode))
+	}
+	if _, ok := ioModes[c.Game.EndMode]; !ok {
+		errs = append(errs, fmt.Errorf("game.end_mode: unknown mode %q", c.Game.EndMode))
+	}
+	if c.Game.Legs < 1 {
+		errs = append(errs, fmt.Errorf("game.legs: must be at least 1, got %d", c.Game.Legs))
+	}
+	if c.Game.Sets < 1 {
+		errs = append(errs, fmt.Errorf("game.sets: must be at least 1, got %d", c.Game.Sets))
+	}
+	return errors.Join(errs...)
+}
+
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("%q is not an origin like https://darts.example.com", origin)
+	}
+	return nil
+}
+
+// Level returns the minimum level to log. The configuration has to be valid.
+func (c *Config) Level() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// MatchDefaults returns a create match request with the default game settings. The configuration has to be valid.
+func (c *Config) MatchDefaults() creatematch.Request {
+	return creatematch.Request{
+		GameType:  gameTypes[c.Game.Type],
+		StartAt:   c.Game.StartAt,
+		StartMode: models.MapIOToNumber(ioModes[c.Game.StartMode]),
+		EndMode:   models.MapIOToNumber(ioModes[c.Game.EndMode]),
+		Legs:      c.Game.Legs,
+		Sets:      c.Game.Sets,
+	}
+}
+
+// String returns the configuration as YAML, hiding the password of a postgres URL.
+func (c *Config) String() string {
+	redacted := *c
+	if u, err := url.Parse(c.DB); err == nil && storage.IsPostgres(c.DB) {
+		redacted.DB = u.Redacted()
+	}
+	out, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func parseBool(v string, dst *bool) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
+
+func parseInt(v string, dst *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
@@ -0,0 +1,2 @@
+// Package config loads the server configuration from flags, environment variables and an optional YAML file.
+package config
@@ -0,0 +1,29 @@
+package apierror
+
+// Code tells clients what went wrong without parsing the message.
+type Code string
+
+const (
+	// BadRequest is a malformed request, e.g. an invalid id or body.
+	BadRequest Code = "bad_request"
+	// NotFound is a player, match, throw or file that does not exist.
+	NotFound Code = "not_found"
+	// MatchFinished is a throw into a match that has been won.
+	MatchFinished Code = "match_finished"
+	// NotYourTurn is a throw by a player who is not up.
+	NotYourTurn Code = "not_your_turn"
+	// InvalidThrow is a throw that is not on the board.
+	InvalidThrow Code = "invalid_throw"
+	// StaleVersion is a throw scored on an outdated version of the match, Details holds the current match.
+	StaleVersion Code = "stale_version"
+	// Internal is an error of the server, its message is not passed on.
+	Internal Code = "internal"
+)
+
+// Response is the body of every error response.
+type Response struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	// Details holds data specific to the code, if any.
+	Details any `json:"details,omitempty"`
+}
@@ -0,0 +1,2 @@
+// Package apierror contains the error response of every endpoint.
+package apierror
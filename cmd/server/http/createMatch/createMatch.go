@@ -1,9 +1,17 @@
 package creatematch
 
+import "darts-counter/models"
+
 // Request represents a create match request payload.
+// Settings left out of the payload take the server's default game settings.
 type Request struct {
-	Pids      []string
-	StartAt   int
-	StartMode uint8
-	EndMode   uint8
+	Pids      []string        `json:"Pids"`
+	GameType  models.GameType `json:"GameType"`
+	StartAt   int             `json:"StartAt"`
+	StartMode uint8           `json:"StartMode"`
+	EndMode   uint8           `json:"EndMode"`
+	// Legs is the number of legs needed to win a set, Sets the number of sets needed to win the match.
+	// Without a server default both are 1, a single leg deciding the match.
+	Legs int `json:"Legs"`
+	Sets int `json:"Sets"`
 }
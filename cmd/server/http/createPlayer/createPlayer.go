@@ -4,7 +4,7 @@ import "darts-counter/models"
 
 // Request represents a create player request payload.
 type Request struct {
-	Name string
+	Name string `json:"Name"`
 }
 
 // Response wraps the created player entity returned to the client.
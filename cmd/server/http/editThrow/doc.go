@@ -0,0 +1,2 @@
+// Package editthrow contains request types for the edit throw endpoint.
+package editthrow
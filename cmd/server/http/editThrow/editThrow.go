@@ -0,0 +1,12 @@
+package editthrow
+
+import "darts-counter/models"
+
+// Request represents an edit throw request payload correcting the throw with ThrowID.
+type Request struct {
+	Mid     string           `json:"Mid"`
+	ThrowID int64            `json:"ThrowID"`
+	Throw   models.ThrowType `json:"Throw"`
+	// Version is the version of the match the edit was asked on, zero skips the check.
+	Version int `json:"Version"`
+}
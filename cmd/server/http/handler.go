@@ -1,30 +1,64 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
 	"github.com/google/uuid"
 
+	apierror "darts-counter/cmd/server/http/apiError"
 	creatematch "darts-counter/cmd/server/http/createMatch"
 	createplayer "darts-counter/cmd/server/http/createPlayer"
+	editthrow "darts-counter/cmd/server/http/editThrow"
 	getmatch "darts-counter/cmd/server/http/getMatch"
+	headtohead "darts-counter/cmd/server/http/headToHead"
+	leaderboard "darts-counter/cmd/server/http/leaderboard"
+	matchevents "darts-counter/cmd/server/http/matchEvents"
+	matchstats "darts-counter/cmd/server/http/matchStats"
 	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	undothrow "darts-counter/cmd/server/http/undoThrow"
 	updateplayer "darts-counter/cmd/server/http/updatePlayer"
 	darts "darts-counter/darts"
+	models "darts-counter/models"
 	storage "darts-counter/storage"
 )
 
+const (
+	// eventWriteTimeout is how long the event streams wait for a client to take an event before giving up on it.
+	eventWriteTimeout = 10 * time.Second
+	// feedKeepAlive is how often the event feeds write a comment so that idle connections are not cut by proxies.
+	feedKeepAlive = 30 * time.Second
+)
+
+// Options holds the settings of the HTTP API.
+type Options struct {
+	// Assets is the directory StreamFile serves files from.
+	Assets string
+	// MatchDefaults holds the settings of matches created without them.
+	MatchDefaults creatematch.Request
+	// CORSOrigins are the origins allowed to open WebSockets, "*" allows every origin.
+	CORSOrigins []string
+}
+
 // Impl provides HTTP handlers for the darts-counter API.
 type Impl struct {
-	Store        *storage.Storage
+	Store        storage.Repository
 	DartsService *darts.Service
+	Options
 }
 
 // Api defines the HTTP API surface.
@@ -38,7 +72,16 @@ type Api interface {
 	DeleteMatch(w http.ResponseWriter, r *http.Request)
 	GetMatch(w http.ResponseWriter, r *http.Request)
 	PlayerThrow(w http.ResponseWriter, r *http.Request)
+	UndoThrow(w http.ResponseWriter, r *http.Request)
+	EditThrow(w http.ResponseWriter, r *http.Request)
+	MatchEvents(w http.ResponseWriter, r *http.Request)
+	MatchFeed(w http.ResponseWriter, r *http.Request)
+	ActiveMatchesFeed(w http.ResponseWriter, r *http.Request)
 	Statistics(w http.ResponseWriter, r *http.Request)
+	MatchStatistics(w http.ResponseWriter, r *http.Request)
+	HeadToHead(w http.ResponseWriter, r *http.Request)
+	Rating(w http.ResponseWriter, r *http.Request)
+	Leaderboard(w http.ResponseWriter, r *http.Request)
 	StreamFile(w http.ResponseWriter, r *http.Request)
 }
 
@@ -46,32 +89,32 @@ type Api interface {
 func (i *Impl) CreatePlayer(w http.ResponseWriter, r *http.Request) {
 	req := &createplayer.Request{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Name) < 1 {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+		badRequest(w, "invalid request")
 		return
 	}
 
-	p, err := i.Store.CreatePlayer(req.Name)
+	p, err := i.Store.CreatePlayer(r.Context(), req.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(p); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, p)
 }
 
 // UpdatePlayer updates an existing player.
 func (i *Impl) UpdatePlayer(w http.ResponseWriter, r *http.Request) {
 	req := &updateplayer.Request{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+		badRequest(w, "invalid request")
 		return
 	}
+	if id := r.PathValue("id"); id != "" {
+		req.ID = id
+	}
 
 	if len(req.Name) < 1 {
-		http.Error(w, "invalid name change requested", http.StatusBadRequest)
+		badRequest(w, "invalid name change requested")
 		return
 	}
 
@@ -79,22 +122,72 @@ func (i *Impl) UpdatePlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, err := i.Store.UpdatePlayer(req.ID, req.Name)
+	p, err := i.Store.UpdatePlayer(r.Context(), req.ID, req.Name)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(p); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	writeJSON(w, http.StatusOK, p)
+}
+
+// writeJSON encodes v as the body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// the status is out already, all that is left is to log it
+		slog.Error("encode response", "err", err)
 	}
 }
 
+// badRequest writes an apierror.BadRequest response with message.
+func badRequest(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusBadRequest, apierror.Response{Code: apierror.BadRequest, Message: message})
+}
+
+// writeError writes err as an apierror.Response with the status and code of the sentinel error it wraps.
+// Any other error is an internal one: it is logged, but its message, which may come from the database, is not passed on.
+func writeError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	message := err.Error()
+	if code == apierror.Internal {
+		slog.Error("internal error", "err", err)
+		message = "internal error"
+	}
+	writeJSON(w, status, apierror.Response{Code: code, Message: message})
+}
+
+func errorStatus(err error) (int, apierror.Code) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, apierror.NotFound
+	case errors.Is(err, darts.ErrMatchFinished):
+		return http.StatusConflict, apierror.MatchFinished
+	case errors.Is(err, darts.ErrNotYourTurn):
+		return http.StatusConflict, apierror.NotYourTurn
+	case errors.Is(err, storage.ErrVersionConflict):
+		return http.StatusConflict, apierror.StaleVersion
+	case errors.Is(err, darts.ErrInvalidThrow):
+		return http.StatusBadRequest, apierror.InvalidThrow
+	case errors.Is(err, darts.ErrInvalidRequest):
+		return http.StatusBadRequest, apierror.BadRequest
+	}
+	return http.StatusInternalServerError, apierror.Internal
+}
+
+// pathOrQuery returns the path value name of the /api/v1 routes, or the query parameter query of the deprecated ones.
+func pathOrQuery(r *http.Request, name, query string) string {
+	if v := r.PathValue(name); v != "" {
+		return v
+	}
+	return r.URL.Query().Get(query)
+}
+
 func validUUID(w http.ResponseWriter, id string) bool {
 	if err := uuid.Validate(id); err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		badRequest(w, "invalid id")
 		return false
 	}
 	return true
@@ -102,195 +195,516 @@ func validUUID(w http.ResponseWriter, id string) bool {
 
 // DeletePlayer deletes a player by ID.
 func (i *Impl) DeletePlayer(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("playerId")
+	id := pathOrQuery(r, "id", "playerId")
 	if !validUUID(w, id) {
 		return
 	}
 
-	if err := i.Store.DeletePlayer(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := i.Store.DeletePlayer(r.Context(), id); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "player deleted"}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "player deleted"})
 }
 
 // ListPlayers lists all players.
-func (i *Impl) ListPlayers(w http.ResponseWriter, _ *http.Request) {
-	players, err := i.Store.GetPlayers()
+func (i *Impl) ListPlayers(w http.ResponseWriter, r *http.Request) {
+	players, err := i.Store.GetPlayers(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if err := json.NewEncoder(w).Encode(players); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, players)
 }
 
 // CreateMatch creates a new match.
 func (i *Impl) CreateMatch(w http.ResponseWriter, r *http.Request) {
-	req := &creatematch.Request{}
+	// settings left out of the request keep their defaults
+	req := i.MatchDefaults
+	req.Pids = nil
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, "invalid request: "+err.Error())
 		return
 	}
 
-	if req == nil || len(req.Pids) < 1 {
-		http.Error(w, "empty request", http.StatusBadRequest)
+	if len(req.Pids) < 1 {
+		badRequest(w, "empty request")
 		return
 	}
 
 	for _, pid := range req.Pids {
 		if err := uuid.Validate(pid); err != nil {
-			http.Error(w, "invalid pid(s)", http.StatusBadRequest)
+			badRequest(w, "invalid pid(s)")
 			return
 		}
 	}
 
-	m, err := i.Store.CreateMatch(req.Pids, req.StartAt, req.StartMode, req.EndMode)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err := darts.RulesFor(req.GameType); err != nil {
+		badRequest(w, "invalid game type")
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(m); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	m, err := i.DartsService.CreateMatch(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+
+	writeJSON(w, http.StatusOK, m)
 }
 
 // ListMatches lists all matches.
-func (i *Impl) ListMatches(w http.ResponseWriter, _ *http.Request) {
-	matches, err := i.Store.GetMatches()
+func (i *Impl) ListMatches(w http.ResponseWriter, r *http.Request) {
+	matches, err := i.Store.GetMatches(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(matches); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, matches)
 }
 
 // GetMatch returns a match along with relevant throws per player (see docs).
 func (i *Impl) GetMatch(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("matchId")
+	id := pathOrQuery(r, "id", "matchId")
 	if !validUUID(w, id) {
 		return
 	}
-	match, err := i.Store.GetMatch(id)
+	match, err := i.Store.GetMatch(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	throwsHistory, err := i.DartsService.GetHistory(match)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	i.writeMatch(w, r, match)
+}
 
+// writeMatch encodes a match along with its throw history as a getmatch.Response.
+func (i *Impl) writeMatch(w http.ResponseWriter, r *http.Request, match *models.Match) {
+	resp, err := i.matchResponse(r.Context(), match)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	resp := getmatch.Response{
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// matchResponse returns a match along with its throw history.
+func (i *Impl) matchResponse(ctx context.Context, match *models.Match) (*getmatch.Response, error) {
+	throwsHistory, err := i.DartsService.GetHistory(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	return &getmatch.Response{
 		Match:   match,
 		History: throwsHistory,
-	}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	}, nil
 }
 
 // DeleteMatch deletes a match by ID.
 func (i *Impl) DeleteMatch(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("matchId")
+	id := pathOrQuery(r, "id", "matchId")
 	if err := uuid.Validate(id); err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
-		return
-	}
-	if err := i.Store.DeleteMatch(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		badRequest(w, "invalid id")
 		return
 	}
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "match deleted"}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := i.Store.DeleteMatch(r.Context(), id); err != nil {
+		writeError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "match deleted"})
 }
 
 // PlayerThrow records a player's throw within a match.
 func (i *Impl) PlayerThrow(w http.ResponseWriter, r *http.Request) {
 	req := &playerthrow.Request{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, "invalid request: "+err.Error())
 		return
 	}
 
 	if req == nil {
-		http.Error(w, "req is nil", http.StatusBadRequest)
+		badRequest(w, "req is nil")
 		return
 	}
+	if mid := r.PathValue("id"); mid != "" {
+		req.Mid = mid
+	}
 
-	if err := uuid.Validate(req.Pid); err != nil {
-		http.Error(w, "invalid Pid", http.StatusBadRequest)
-		return
+	// without a Pid the throw goes to whoever is up (scorer mode)
+	if req.Pid != "" {
+		if err := uuid.Validate(req.Pid); err != nil {
+			badRequest(w, "invalid Pid")
+			return
+		}
 	}
 
 	if err := uuid.Validate(req.Mid); err != nil {
-		http.Error(w, "invalid Mid", http.StatusBadRequest)
+		badRequest(w, "invalid Mid")
 		return
 	}
 
-	resp, err := i.DartsService.PlayerThrow(req)
+	resp, err := i.DartsService.PlayerThrow(r.Context(), req)
+	if i.writeStale(w, r, err) {
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeStale answers a change made on an outdated version of a match with 409 and the current match,
+// and reports whether err was such a change.
+func (i *Impl) writeStale(w http.ResponseWriter, r *http.Request, err error) bool {
+	var stale *darts.StaleVersionError
+	if !errors.As(err, &stale) {
+		return false
+	}
+	// the client acted on an outdated state, send it the current one
+	current, merr := i.matchResponse(r.Context(), stale.Match)
+	if merr != nil {
+		writeError(w, merr)
+		return true
+	}
+	writeJSON(w, http.StatusConflict, apierror.Response{Code: apierror.StaleVersion, Message: err.Error(), Details: current})
+	return true
+}
+
+// UndoThrow removes the latest throw of a match and returns the rolled back match.
+func (i *Impl) UndoThrow(w http.ResponseWriter, r *http.Request) {
+	// the match is in the path of the REST route, where the body is optional and only carries the version
+	mid := r.PathValue("id")
+	req := &undothrow.Request{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && (mid == "" || !errors.Is(err, io.EOF)) {
+		badRequest(w, "invalid request: "+err.Error())
 		return
 	}
+	if mid != "" {
+		req.Mid = mid
+	}
+
+	if !validUUID(w, req.Mid) {
+		return
+	}
+
+	match, err := i.DartsService.UndoThrow(r.Context(), req.Mid, req.Version)
+	if i.writeStale(w, r, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	i.writeMatch(w, r, match)
+}
+
+// EditThrow corrects a recorded throw and returns the recomputed match.
+func (i *Impl) EditThrow(w http.ResponseWriter, r *http.Request) {
+	req := &editthrow.Request{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid request: "+err.Error())
+		return
+	}
+	if mid := r.PathValue("id"); mid != "" {
+		req.Mid = mid
+	}
+	if v := r.PathValue("throwId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			badRequest(w, "invalid throw id")
+			return
+		}
+		req.ThrowID = id
+	}
+
+	if !validUUID(w, req.Mid) {
+		return
+	}
+
+	match, err := i.DartsService.EditThrow(r.Context(), req.Mid, req.ThrowID, req.Throw, req.Version)
+	if i.writeStale(w, r, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	i.writeMatch(w, r, match)
+}
+
+// MatchEvents upgrades to a WebSocket that receives the current state of a match
+// followed by a matchevents.Event for every change of it, until either side closes it.
+func (i *Impl) MatchEvents(w http.ResponseWriter, r *http.Request) {
+	id := pathOrQuery(r, "id", "matchId")
+	if !validUUID(w, id) {
+		return
+	}
+
+	// subscribe before loading the match so that no change gets lost in between
+	events, unsubscribe := i.DartsService.Events.Subscribe(id)
+	defer unsubscribe()
+	match, err := i.Store.GetMatch(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c, err := websocket.Accept(w, r, i.acceptOptions())
+	if err != nil {
+		// Accept has already written the error response
+		return
+	}
+	defer func() { _ = c.CloseNow() }()
+	// the client only ever sends close frames, the context ends once it does
+	ctx := c.CloseRead(r.Context())
+
+	state := matchevents.Event{Type: matchevents.State, Mid: id, Version: match.Version, Match: match}
+	if err := writeEvent(ctx, c, state); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				_ = c.Close(websocket.StatusTryAgainLater, "too far behind, reconnect")
+				return
+			}
+			// already part of the state sent first
+			if ev.Version <= state.Version {
+				continue
+			}
+			if err := writeEvent(ctx, c, ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(ctx context.Context, c *websocket.Conn, ev matchevents.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, eventWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, c, ev)
+}
+
+// acceptOptions lets the WebSocket handshake through for the origins allowed to call the API.
+func (i *Impl) acceptOptions() *websocket.AcceptOptions {
+	if slices.Contains(i.CORSOrigins, "*") {
+		return &websocket.AcceptOptions{InsecureSkipVerify: true}
+	}
+	opts := &websocket.AcceptOptions{}
+	for _, origin := range i.CORSOrigins {
+		if u, err := url.Parse(origin); err == nil {
+			opts.OriginPatterns = append(opts.OriginPatterns, u.Host)
+		}
+	}
+	return opts
+}
+
+// MatchFeed streams the events of a match as Server-Sent Events, see serveFeed.
+func (i *Impl) MatchFeed(w http.ResponseWriter, r *http.Request) {
+	id := pathOrQuery(r, "id", "matchId")
+	if !validUUID(w, id) {
+		return
+	}
+	i.serveFeed(w, r, id)
+}
+
+// ActiveMatchesFeed streams the events of every active match as Server-Sent Events, see serveFeed.
+func (i *Impl) ActiveMatchesFeed(w http.ResponseWriter, r *http.Request) {
+	i.serveFeed(w, r, "")
+}
+
+// serveFeed streams the state of the match mid, or of every active match if mid is empty, followed by
+// every change of it as Server-Sent Events named after the matchevents.Type. Throw events have their throw ID
+// as event ID, a client reconnecting with a Last-Event-ID first gets the throws it missed.
+func (i *Impl) serveFeed(w http.ResponseWriter, r *http.Request, mid string) {
+	var after int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			badRequest(w, "invalid Last-Event-ID")
+			return
+		}
+		after = id
+	}
+
+	// subscribe before taking the snapshot so that no change gets lost in between
+	events, unsubscribe := i.DartsService.Events.Subscribe(mid)
+	defer unsubscribe()
+	snapshot, err := i.DartsService.Snapshot(r.Context(), mid, after)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	// the versions sent with the snapshot, older changes are part of it
+	versions := map[string]int{}
+	for _, ev := range snapshot {
+		if ev.Type == matchevents.State {
+			versions[ev.Mid] = ev.Version
+		}
+		if err := writeFeedEvent(w, rc, ev); err != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(feedKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if err := writeFeed(w, rc, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-events:
+			// a client too far behind is cut off, it reconnects with its Last-Event-ID
+			if !ok {
+				return
+			}
+			if ev.Version <= versions[ev.Mid] {
+				continue
+			}
+			if err := writeFeedEvent(w, rc, ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeFeedEvent(w http.ResponseWriter, rc *http.ResponseController, ev matchevents.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("event: %s\ndata: %s\n\n", ev.Type, data)
+	if ev.Type == matchevents.Throw {
+		msg = fmt.Sprintf("id: %d\n", ev.ThrowID) + msg
+	}
+	return writeFeed(w, rc, msg)
+}
+
+func writeFeed(w http.ResponseWriter, rc *http.ResponseController, msg string) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := fmt.Fprint(w, msg); err != nil {
+		return err
+	}
+	return rc.Flush()
 }
 
 // Statistics returns aggregated statistics for a player.
 func (i *Impl) Statistics(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("playerId")
+	id := pathOrQuery(r, "id", "playerId")
 	if err := uuid.Validate(id); err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		badRequest(w, "invalid id")
+		return
+	}
+	playerStats, err := i.DartsService.CollectStats(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, playerStats)
+}
+
+// MatchStatistics returns the X01 metrics of every player in a match.
+func (i *Impl) MatchStatistics(w http.ResponseWriter, r *http.Request) {
+	id := pathOrQuery(r, "id", "matchId")
+	if !validUUID(w, id) {
+		return
+	}
+	players, err := i.DartsService.MatchStats(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	playerStats, err := i.DartsService.CollectStats(id)
+
+	writeJSON(w, http.StatusOK, matchstats.Response{Players: players})
+}
+
+// HeadToHead returns the record between two players.
+func (i *Impl) HeadToHead(w http.ResponseWriter, r *http.Request) {
+	req := headtohead.Request{
+		Pid:         pathOrQuery(r, "id", "playerId"),
+		OpponentPid: pathOrQuery(r, "opponentId", "opponentId"),
+	}
+	if !validUUID(w, req.Pid) || !validUUID(w, req.OpponentPid) {
+		return
+	}
+	resp, err := i.DartsService.HeadToHead(r.Context(), req.Pid, req.OpponentPid)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Rating returns the current rating of a player and its history.
+func (i *Impl) Rating(w http.ResponseWriter, r *http.Request) {
+	id := pathOrQuery(r, "id", "playerId")
+	if !validUUID(w, id) {
+		return
+	}
+	resp, err := i.DartsService.Rating(r.Context(), id)
 	if err != nil {
-		http.Error(w, "player not found", http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(playerStats); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Leaderboard ranks the players by a metric, optionally restricted to a time window and a minimum of matches.
+func (i *Impl) Leaderboard(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := leaderboard.Request{Metric: q.Get("metric"), Window: q.Get("window")}
+	if v := q.Get("minMatches"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badRequest(w, "invalid minMatches")
+			return
+		}
+		req.MinMatches = n
+	}
+	resp, err := i.DartsService.Leaderboard(r.Context(), &req)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // StreamFile streams a file from the assets directory with basic content type handling.
 func (i *Impl) StreamFile(w http.ResponseWriter, r *http.Request) {
-	file := r.URL.Query().Get("file")
+	file := pathOrQuery(r, "name", "file")
 	if file == "" {
-		http.Error(w, "missing file parameter", http.StatusBadRequest)
+		badRequest(w, "missing file parameter")
 		return
 	}
 
 	// Restrict serving to the assets directory and prevent path traversal.
 	base := filepath.Base(file)
-	path := fmt.Sprintf("./assets/%s", base)
+	path := filepath.Join(i.Assets, base)
 
 	f, err := os.Open(path)
 	if err != nil {
-		http.Error(w, "file not found", http.StatusNotFound)
+		writeJSON(w, http.StatusNotFound, apierror.Response{Code: apierror.NotFound, Message: "file not found"})
 		return
 	}
 	defer func() { _ = f.Close() }()
@@ -310,8 +724,8 @@ func (i *Impl) StreamFile(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, base, time.Now(), f)
 }
 
-// NewApi constructs the HTTP API implementation.
-func NewApi(db *storage.Storage, dartsService *darts.Service) (Api, error) {
+// NewApi constructs the HTTP API implementation with the given options.
+func NewApi(db storage.Repository, dartsService *darts.Service, opts Options) (Api, error) {
 	if db == nil || dartsService == nil {
 		return nil, errors.New("db or dartsService service is nil")
 	}
@@ -319,5 +733,6 @@ func NewApi(db *storage.Storage, dartsService *darts.Service) (Api, error) {
 	return &Impl{
 		Store:        db,
 		DartsService: dartsService,
+		Options:      opts,
 	}, nil
 }
@@ -0,0 +1,2 @@
+// Package headtohead contains request/response types for the head-to-head endpoint.
+package headtohead
@@ -0,0 +1,28 @@
+package headtohead
+
+import "darts-counter/models"
+
+// Request represents a head-to-head request payload for two players.
+type Request struct {
+	Pid         string `json:"Pid"`
+	OpponentPid string `json:"OpponentPid"`
+}
+
+// Record is one player's side of a head-to-head.
+type Record struct {
+	Player *models.Player `json:"Player"`
+	Wins   int            `json:"Wins"`
+	// ThreeDartAverage and HighestCheckout cover the shared X01 matches.
+	ThreeDartAverage float32 `json:"ThreeDartAverage"`
+	HighestCheckout  int     `json:"HighestCheckout"`
+}
+
+// Response holds the record between two players and the matches they played against each other.
+type Response struct {
+	// Played counts the shared matches, Finished those that have a winner.
+	Played   int             `json:"Played"`
+	Finished int             `json:"Finished"`
+	Player   Record          `json:"Player"`
+	Opponent Record          `json:"Opponent"`
+	Matches  []*models.Match `json:"Matches"`
+}
@@ -0,0 +1,2 @@
+// Package leaderboard contains request/response types for the leaderboard endpoint.
+package leaderboard
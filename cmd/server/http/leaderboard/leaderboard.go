@@ -0,0 +1,48 @@
+package leaderboard
+
+import "darts-counter/models"
+
+// Metrics players can be ranked by.
+const (
+	WinRate          = "winRate"
+	ThreeDartAverage = "average"
+	Checkout         = "checkout"
+	OneEighties      = "180s"
+	Rating           = "rating"
+)
+
+// Time windows the leaderboard can be restricted to.
+const (
+	LastWeek  = "7d"
+	LastMonth = "30d"
+	AllTime   = "all"
+)
+
+// Request represents a leaderboard request payload.
+// Metric defaults to WinRate and Window to AllTime.
+type Request struct {
+	Metric     string `json:"Metric"`
+	Window     string `json:"Window"`
+	MinMatches int    `json:"MinMatches"`
+}
+
+// Entry is one player's row on the leaderboard.
+// Everything but Rating covers the finished matches in the window, the X01 metrics only X01 matches.
+type Entry struct {
+	Rank               int            `json:"Rank"`
+	Player             *models.Player `json:"Player"`
+	Matches            int            `json:"Matches"`
+	Wins               int            `json:"Wins"`
+	WinRate            float32        `json:"WinRate"`
+	ThreeDartAverage   float32        `json:"ThreeDartAverage"`
+	CheckoutPercentage float32        `json:"CheckoutPercentage"`
+	OneEighties        int            `json:"OneEighties"`
+	Rating             float64        `json:"Rating"`
+}
+
+// Response holds the players ranked by Metric, best first. Players with equal values share a rank.
+type Response struct {
+	Metric  string  `json:"Metric"`
+	Window  string  `json:"Window"`
+	Entries []Entry `json:"Entries"`
+}
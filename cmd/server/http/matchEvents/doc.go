@@ -0,0 +1,2 @@
+// Package matchevents contains the event types pushed by the match events endpoint.
+package matchevents
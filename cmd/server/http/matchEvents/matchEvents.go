@@ -0,0 +1,41 @@
+package matchevents
+
+import "darts-counter/models"
+
+// Type tells what changed in a match.
+type Type string
+
+const (
+	// State carries the current state of the match, it is sent on every connection before any change.
+	State Type = "state"
+	// Throw is a recorded throw of Pid.
+	Throw Type = "throw"
+	// Bust is a throw of Pid that busted the turn, the score is back to the start of the turn.
+	Bust Type = "bust"
+	// Turn passes the turn to Pid.
+	Turn Type = "turn"
+	// Leg is a leg won by Pid, the next leg has started unless the match was won.
+	Leg Type = "leg"
+	// Won is the match won by Pid.
+	Won Type = "won"
+	// Undo removed the latest throw of the match.
+	Undo Type = "undo"
+	// Edit corrected a recorded throw of the match.
+	Edit Type = "edit"
+)
+
+// Event is a change of a match. The events of one throw are sent in the order throw, bust, leg, won, turn,
+// each with the state of the match after the throw.
+type Event struct {
+	Type Type   `json:"Type"`
+	Mid  string `json:"Mid"`
+	// Version is the version of the match after the change. Clients already showing that version can skip the event.
+	// Throws replayed after a reconnect have neither a Version nor a Match, the state sent after them has both.
+	Version int `json:"Version"`
+	// Pid is the player the event is about: the thrower, the player up next or the winner.
+	Pid string `json:"Pid,omitempty"`
+	// ThrowID and Throw are the recorded throw of throw, undo and edit events.
+	ThrowID int64            `json:"ThrowID,omitempty"`
+	Throw   models.ThrowType `json:"Throw,omitempty"`
+	Match   *models.Match    `json:"Match"`
+}
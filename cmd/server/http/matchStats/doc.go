@@ -0,0 +1,2 @@
+// Package matchstats contains request/response types for the match statistics endpoint.
+package matchstats
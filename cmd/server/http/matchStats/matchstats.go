@@ -0,0 +1,13 @@
+package matchstats
+
+import "darts-counter/models"
+
+// Request represents a match statistics request payload.
+type Request struct {
+	Mid string `json:"Mid"`
+}
+
+// Response holds the X01 metrics of every player in a match keyed by player ID.
+type Response struct {
+	Players map[string]models.X01Stats `json:"Players"`
+}
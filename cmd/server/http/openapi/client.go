@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Client returns the source of the methods of the Go client in package pkg, one per endpoint of Endpoints
+// without a Stream. The package provides the Client type and its do method.
+func Client(pkg string) ([]byte, error) {
+	imports := map[string]string{"context": "context"}
+	var methods bytes.Buffer
+	for _, e := range Endpoints {
+		if e.Stream != "" {
+			continue
+		}
+		clientMethod(&methods, e, imports)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by cmd/openapi from the endpoints of package openapi. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		if name := imports[path]; name != path[strings.LastIndex(path, "/")+1:] {
+			fmt.Fprintf(&out, "\t%s %q\n", name, path)
+		} else {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(methods.Bytes())
+	return format.Source(out.Bytes())
+}
+
+func clientMethod(w *bytes.Buffer, e Endpoint, imports map[string]string) {
+	args := []string{"ctx context.Context"}
+	path := fmt.Sprintf("%q", e.Path)
+	var query bool
+	for _, p := range e.Params {
+		if p.in(e.Path) == "query" {
+			query = true
+			continue
+		}
+		value := "url.PathEscape(" + p.Name + ")"
+		args = append(args, p.Name+" string")
+		if p.Type == "integer" {
+			imports["strconv"] = "strconv"
+			value = "strconv.FormatInt(" + p.Name + ", 10)"
+			args[len(args)-1] = p.Name + " int64"
+		}
+		imports["net/url"] = "url"
+		path = strings.Replace(path, "{"+p.Name+"}", `" + `+value+` + "`, 1)
+	}
+	path = strings.TrimSuffix(path, ` + ""`)
+	queryArg := "nil"
+	if query {
+		imports["net/url"] = "url"
+		args = append(args, "query url.Values")
+		queryArg = "query"
+	}
+	bodyArg := "nil"
+	if e.Body != nil {
+		args = append(args, "body *"+typeExpr(reflect.TypeOf(e.Body), imports))
+		bodyArg = "body"
+	}
+
+	result := reflect.TypeOf(e.Result)
+	resultType, out, zero := typeExpr(result, imports), "out", "nil"
+	if result.Kind() == reflect.Struct {
+		resultType, out = "*"+resultType, "&out"
+	}
+
+	fmt.Fprintf(w, "\n// %s %s\n//\n// %s %s\n", e.ID, lowerFirst(e.Summary), e.Method, e.Path)
+	if query {
+		var names []string
+		for _, p := range e.Params {
+			if p.in(e.Path) == "query" {
+				names = append(names, p.Name)
+			}
+		}
+		fmt.Fprintf(w, "//\n// The query may set %s.\n", strings.Join(names, ", "))
+	}
+	fmt.Fprintf(w, "func (c *Client) %s(%s) (%s, error) {\n", e.ID, strings.Join(args, ", "), resultType)
+	fmt.Fprintf(w, "\tvar out %s\n", typeExpr(result, imports))
+	fmt.Fprintf(w, "\tif err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\n\t\treturn %s, err\n\t}\n", e.Method, path, queryArg, bodyArg, zero)
+	fmt.Fprintf(w, "\treturn %s, nil\n}\n", out)
+}
+
+// typeExpr returns the Go expression of t, adding the packages it needs to imports.
+func typeExpr(t reflect.Type, imports map[string]string) string {
+	if t.Name() != "" && t.PkgPath() != "" {
+		name, _, _ := strings.Cut(t.String(), ".")
+		imports[t.PkgPath()] = name
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + typeExpr(t.Elem(), imports)
+	case reflect.Slice:
+		return "[]" + typeExpr(t.Elem(), imports)
+	case reflect.Map:
+		return "map[" + typeExpr(t.Key(), imports) + "]" + typeExpr(t.Elem(), imports)
+	}
+	return t.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
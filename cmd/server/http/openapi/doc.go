@@ -0,0 +1,3 @@
+// Package openapi describes the /api/v1 endpoints as an OpenAPI 3 document built from the request and
+// response types of the handlers, and generates the Go client package from the same description.
+package openapi
@@ -0,0 +1,237 @@
+package openapi
+
+import (
+	"net/http"
+
+	creatematch "darts-counter/cmd/server/http/createMatch"
+	createplayer "darts-counter/cmd/server/http/createPlayer"
+	editthrow "darts-counter/cmd/server/http/editThrow"
+	getmatch "darts-counter/cmd/server/http/getMatch"
+	headtohead "darts-counter/cmd/server/http/headToHead"
+	leaderboard "darts-counter/cmd/server/http/leaderboard"
+	matchevents "darts-counter/cmd/server/http/matchEvents"
+	matchstats "darts-counter/cmd/server/http/matchStats"
+	playerstats "darts-counter/cmd/server/http/playerStats"
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	rating "darts-counter/cmd/server/http/rating"
+	undothrow "darts-counter/cmd/server/http/undoThrow"
+	updateplayer "darts-counter/cmd/server/http/updatePlayer"
+	models "darts-counter/models"
+)
+
+// Content types of the responses the generated client leaves out.
+const (
+	EventStream = "text/event-stream"
+	JSONContent = "application/json"
+	// AnyContent is the content type of files, it depends on the file.
+	AnyContent = "*/*"
+	// WebSocket is not a content type, the response switches protocols.
+	WebSocket = "websocket"
+)
+
+// Endpoint describes an endpoint of the API.
+type Endpoint struct {
+	// ID names the operation and the method of the generated client.
+	ID      string
+	Method  string
+	Path    string
+	Summary string
+	// Params lists the path parameters of Path and the query parameters.
+	Params []Param
+	// Body and Result are values of the request and response body types, nil without a body.
+	Body   any
+	Result any
+	// BodyOptional tells that the request may come without Body.
+	BodyOptional bool
+	// Stream is the content type of a response the generated client does not decode: a stream of events
+	// described by Result, a file or this document. The client leaves such endpoints out.
+	Stream string
+	// Errors lists the statuses of the apierror.Response an endpoint may answer with, besides 500.
+	Errors []int
+}
+
+// Param is a path or query parameter.
+type Param struct {
+	Name string
+	// Type is the JSON schema type, string or integer.
+	Type        string
+	Description string
+}
+
+func (p Param) in(path string) string {
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		if m[1] == p.Name {
+			return "path"
+		}
+	}
+	return "query"
+}
+
+// Status is the status of a successful call of e.
+func (e Endpoint) Status() int {
+	if e.Stream == WebSocket {
+		return http.StatusSwitchingProtocols
+	}
+	return http.StatusOK
+}
+
+var (
+	playerID  = Param{Name: "id", Type: "string", Description: "player ID"}
+	matchID   = Param{Name: "id", Type: "string", Description: "match ID"}
+	badOrGone = []int{http.StatusBadRequest, http.StatusNotFound}
+)
+
+// Endpoints lists every endpoint of the API, the server routes exactly these.
+var Endpoints = []Endpoint{
+	// players
+	{
+		ID: "ListPlayers", Method: http.MethodGet, Path: "/api/v1/players",
+		Summary: "Lists all players.",
+		Result:  []*models.Player{},
+	},
+	{
+		ID: "CreatePlayer", Method: http.MethodPost, Path: "/api/v1/players",
+		Summary: "Creates a player.",
+		Body:    createplayer.Request{}, Result: models.Player{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	{
+		ID: "UpdatePlayer", Method: http.MethodPatch, Path: "/api/v1/players/{id}",
+		Summary: "Renames a player, the ID of the body is ignored.",
+		Params:  []Param{playerID},
+		Body:    updateplayer.Request{}, Result: models.Player{},
+		Errors: badOrGone,
+	},
+	{
+		ID: "DeletePlayer", Method: http.MethodDelete, Path: "/api/v1/players/{id}",
+		Summary: "Deletes a player with its throws, match seats, statistics and rating history. The matches it played are kept.",
+		Params:  []Param{playerID},
+		Result:  map[string]string{},
+		Errors:  []int{http.StatusBadRequest},
+	},
+	{
+		ID: "PlayerStatistics", Method: http.MethodGet, Path: "/api/v1/players/{id}/statistics",
+		Summary: "Returns the lifetime statistics of a player.",
+		Params:  []Param{playerID},
+		Result:  playerstats.Response{},
+		Errors:  badOrGone,
+	},
+	{
+		ID: "Rating", Method: http.MethodGet, Path: "/api/v1/players/{id}/rating",
+		Summary: "Returns the rating of a player and its history.",
+		Params:  []Param{playerID},
+		Result:  rating.Response{},
+		Errors:  badOrGone,
+	},
+	{
+		ID: "HeadToHead", Method: http.MethodGet, Path: "/api/v1/players/{id}/head-to-head/{opponentId}",
+		Summary: "Returns the record between two players.",
+		Params:  []Param{playerID, {Name: "opponentId", Type: "string", Description: "opponent player ID"}},
+		Result:  headtohead.Response{},
+		Errors:  badOrGone,
+	},
+	{
+		ID: "Leaderboard", Method: http.MethodGet, Path: "/api/v1/leaderboard",
+		Summary: "Ranks the players by a metric.",
+		Params: []Param{
+			{Name: "metric", Type: "string", Description: "winRate (default), average, checkout, 180s or rating"},
+			{Name: "window", Type: "string", Description: "7d, 30d or all (default)"},
+			{Name: "minMatches", Type: "integer", Description: "minimum of finished matches in the window"},
+		},
+		Result: leaderboard.Response{},
+		Errors: []int{http.StatusBadRequest},
+	},
+
+	// matches
+	{
+		ID: "ListMatches", Method: http.MethodGet, Path: "/api/v1/matches",
+		Summary: "Lists all matches.",
+		Result:  []*models.Match{},
+	},
+	{
+		ID: "CreateMatch", Method: http.MethodPost, Path: "/api/v1/matches",
+		Summary: "Creates a match, settings left out take the server defaults.",
+		Body:    creatematch.Request{}, Result: models.Match{},
+		Errors: []int{http.StatusBadRequest},
+	},
+	{
+		ID: "GetMatch", Method: http.MethodGet, Path: "/api/v1/matches/{id}",
+		Summary: "Returns a match with its throw history.",
+		Params:  []Param{matchID},
+		Result:  getmatch.Response{},
+		Errors:  badOrGone,
+	},
+	{
+		ID: "DeleteMatch", Method: http.MethodDelete, Path: "/api/v1/matches/{id}",
+		Summary: "Deletes a match.",
+		Params:  []Param{matchID},
+		Result:  map[string]string{},
+		Errors:  []int{http.StatusBadRequest},
+	},
+	{
+		ID: "MatchStatistics", Method: http.MethodGet, Path: "/api/v1/matches/{id}/statistics",
+		Summary: "Returns the X01 metrics of every player in a match.",
+		Params:  []Param{matchID},
+		Result:  matchstats.Response{},
+		Errors:  badOrGone,
+	},
+
+	// gameplay
+	{
+		ID: "PlayerThrow", Method: http.MethodPost, Path: "/api/v1/matches/{id}/throws",
+		Summary: "Records a throw. A stale version is answered with 409 and the current match as details.",
+		Params:  []Param{matchID},
+		Body:    playerthrow.Request{}, Result: playerthrow.Response{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		ID: "UndoThrow", Method: http.MethodDelete, Path: "/api/v1/matches/{id}/throws/last",
+		Summary: "Removes the latest throw of a match. A stale version is answered with 409 and the current match as details.",
+		Params:  []Param{matchID},
+		Body:    undothrow.Request{}, BodyOptional: true, Result: getmatch.Response{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		ID: "EditThrow", Method: http.MethodPut, Path: "/api/v1/matches/{id}/throws/{throwId}",
+		Summary: "Corrects a recorded throw and recomputes the match. A stale version is answered with 409 and the current match as details.",
+		Params:  []Param{matchID, {Name: "throwId", Type: "integer", Description: "throw ID"}},
+		Body:    editthrow.Request{}, Result: getmatch.Response{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+
+	// real-time updates
+	{
+		ID: "MatchEvents", Method: http.MethodGet, Path: "/api/v1/matches/{id}/events",
+		Summary: "Upgrades to a WebSocket receiving the state of a match and every change of it.",
+		Params:  []Param{matchID},
+		Stream:  WebSocket,
+		Errors:  badOrGone,
+	},
+	{
+		ID: "MatchFeed", Method: http.MethodGet, Path: "/api/v1/matches/{id}/feed",
+		Summary: "Streams the state of a match and every change of it as Server-Sent Events.",
+		Params:  []Param{matchID},
+		Result:  matchevents.Event{}, Stream: EventStream,
+		Errors: badOrGone,
+	},
+	{
+		ID: "ActiveMatchesFeed", Method: http.MethodGet, Path: "/api/v1/matches/feed",
+		Summary: "Streams the state of every active match and every change of them as Server-Sent Events.",
+		Result:  matchevents.Event{}, Stream: EventStream,
+		Errors: []int{http.StatusBadRequest},
+	},
+
+	// misc
+	{
+		ID: "StreamFile", Method: http.MethodGet, Path: "/api/v1/files/{name}",
+		Summary: "Streams a file of the assets directory.",
+		Params:  []Param{{Name: "name", Type: "string", Description: "file name"}},
+		Stream:  AnyContent,
+		Errors:  []int{http.StatusNotFound},
+	},
+	{
+		ID: "OpenAPI", Method: http.MethodGet, Path: "/api/v1/openapi.json",
+		Summary: "Returns this document.",
+		Stream:  JSONContent,
+	},
+}
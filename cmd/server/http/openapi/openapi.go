@@ -0,0 +1,277 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "darts-counter/cmd/server/http/apiError"
+)
+
+// Document is an OpenAPI 3.0 document, holding just the parts this API uses.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// PathItem holds the operations of a path keyed by lower case method.
+type PathItem map[string]*Operation
+
+// Operation is an endpoint of the document.
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body of a request.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is the response of an operation with a status.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Components holds the schemas the operations refer to.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is a JSON schema as far as the encoding/json output of Go types needs it.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// Spec returns the OpenAPI document of Endpoints.
+func Spec() *Document {
+	g := &generator{schemas: map[string]*Schema{}}
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:   "darts-counter",
+			Version: "1.0.0",
+			Description: "The darts-counter API. The flat routes of earlier versions, e.g. /createPlayer, " +
+				"are deprecated aliases of these endpoints and not described here.",
+		},
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: g.schemas},
+	}
+	errSchema := g.schema(reflect.TypeOf(apierror.Response{}), false)
+	for _, e := range Endpoints {
+		op := &Operation{OperationID: e.ID, Summary: e.Summary, Responses: map[string]Response{}}
+		for _, p := range e.Params {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        p.Name,
+				In:          p.in(e.Path),
+				Description: p.Description,
+				Required:    p.in(e.Path) == "path",
+				Schema:      &Schema{Type: p.Type},
+			})
+		}
+		if e.Body != nil {
+			op.RequestBody = &RequestBody{Required: !e.BodyOptional, Content: map[string]MediaType{
+				"application/json": {Schema: g.schema(reflect.TypeOf(e.Body), true)},
+			}}
+		}
+		op.Responses[strconv.Itoa(e.Status())] = g.success(e)
+		for _, status := range append(e.Errors, http.StatusInternalServerError) {
+			op.Responses[strconv.Itoa(status)] = Response{
+				Description: http.StatusText(status),
+				Content:     map[string]MediaType{"application/json": {Schema: errSchema}},
+			}
+		}
+		if doc.Paths[e.Path] == nil {
+			doc.Paths[e.Path] = PathItem{}
+		}
+		doc.Paths[e.Path][strings.ToLower(e.Method)] = op
+	}
+	return doc
+}
+
+// JSON returns the document of Spec as indented JSON.
+func JSON() ([]byte, error) {
+	out, err := json.MarshalIndent(Spec(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// Handler serves the document of Spec.
+func Handler() http.Handler {
+	spec, err := JSON()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(spec)
+	})
+}
+
+// Resolve returns the schema s refers to, or s itself.
+func (d *Document) Resolve(s *Schema) *Schema {
+	if s != nil && s.Ref != "" {
+		return d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	return s
+}
+
+// success returns the response of a successful call of e.
+func (g *generator) success(e Endpoint) Response {
+	resp := Response{Description: http.StatusText(e.Status())}
+	switch {
+	case e.Stream == WebSocket:
+		resp.Description = "Switching to a WebSocket receiving the JSON encoded events"
+	case e.Stream != "":
+		resp.Content = map[string]MediaType{e.Stream: {}}
+		if e.Result != nil {
+			resp.Content[e.Stream] = MediaType{Schema: g.schema(reflect.TypeOf(e.Result), false)}
+		}
+	case e.Result != nil:
+		resp.Content = map[string]MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(e.Result), false)}}
+	}
+	return resp
+}
+
+// generator builds the schemas of Go types the way encoding/json encodes them.
+type generator struct {
+	schemas map[string]*Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t. Named structs become components that are referred to. The fields of a
+// request body are not required, the handlers keep the defaults of the fields left out.
+func (g *generator) schema(t reflect.Type, request bool) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem(), request))
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Nullable: t.Kind() == reflect.Slice, Items: g.schema(t.Elem(), request)}
+	case reflect.Map:
+		return &Schema{Type: "object", Nullable: true, AdditionalProperties: g.schema(t.Elem(), request)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, request)
+		}
+		name := t.String()
+		if _, ok := g.schemas[name]; !ok {
+			// registered first so that recursive types refer to themselves
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t, request)
+		}
+		return &Schema{Ref: refPrefix + name}
+	}
+	panic(fmt.Sprintf("openapi: no schema for %s", t))
+}
+
+func (g *generator) object(t reflect.Type, request bool) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, s, request)
+	return s
+}
+
+// fields adds the fields of the struct t to s, flattening embedded structs like encoding/json.
+func (g *generator) fields(t reflect.Type, s *Schema, request bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s, request)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schema(f.Type, request)
+		if !request && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	out := *s
+	out.Nullable = true
+	return &out
+}
@@ -0,0 +1,104 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Validate reports where v, a value decoded from JSON into any, does not match the schema s:
+// a value of the wrong type, a property the schema does not know or a required property left out.
+func (d *Document) Validate(s *Schema, v any) error {
+	return d.validate("$", s, v)
+}
+
+func (d *Document) validate(path string, s *Schema, v any) error {
+	s = d.Resolve(s)
+	if s == nil {
+		return fmt.Errorf("%s: unknown schema", path)
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", path)
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(path, sub, v); err != nil {
+			return err
+		}
+	}
+
+	switch s.Type {
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", path, v)
+		}
+	case "integer":
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return fmt.Errorf("%s: expected an integer, got %v", path, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected a number, got %T", path, v)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected a string, got %T", path, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", path, v)
+		}
+		for i, item := range items {
+			if err := d.validate(fmt.Sprintf("%s[%d]", path, i), s.Items, item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", path, v)
+		}
+		return d.validateObject(path, s, obj)
+	}
+	return nil
+}
+
+func (d *Document) validateObject(path string, s *Schema, obj map[string]any) error {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: required property %s is missing", path, name)
+		}
+	}
+	// sorted so that the same mismatch is reported every time
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		prop, ok := s.Properties[k]
+		if !ok {
+			prop = s.AdditionalProperties
+		}
+		if prop == nil {
+			return fmt.Errorf("%s: unknown property %s, expected one of %v", path, k, propertyNames(s))
+		}
+		if err := d.validate(path+"."+k, prop, obj[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func propertyNames(s *Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
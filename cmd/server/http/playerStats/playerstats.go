@@ -2,18 +2,27 @@ package playerstats
 
 import "darts-counter/models"
 
+// Request represents a player stats request payload.
 type Request struct {
-	Pid string
+	Pid string `json:"Pid"`
 }
 
+// Response holds the lifetime statistics of a player.
 type Response struct {
-	Name          string
-	Throws        int
-	Matches       int
-	ActiveMatches int
-	WinRate       float32
-	MeanThrow     float32
-	HighestFinish uint32
-	Nemesis       *models.Player
-	Dominating    *models.Player
+	Name string `json:"Name"`
+	// Throws counts every dart thrown, Matches every match taken part in, including ActiveMatches.
+	Throws        int `json:"Throws"`
+	Matches       int `json:"Matches"`
+	ActiveMatches int `json:"ActiveMatches"`
+	// WinRate is the share of finished matches won (0-1).
+	WinRate float32 `json:"WinRate"`
+	// MeanThrow is the mean points per dart in X01, busted darts count as zero.
+	MeanThrow float32 `json:"MeanThrow"`
+	// HighestFinish is the highest X01 checkout.
+	HighestFinish uint32 `json:"HighestFinish"`
+	// Nemesis has beaten the player most often, Dominating was beaten by the player most often.
+	Nemesis    *models.Player `json:"Nemesis"`
+	Dominating *models.Player `json:"Dominating"`
+	// X01 holds the lifetime averages, checkout and scoring metrics over all X01 matches.
+	X01 models.X01Stats `json:"X01"`
 }
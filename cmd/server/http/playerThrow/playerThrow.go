@@ -3,15 +3,25 @@ package playerthrow
 import "darts-counter/models"
 
 type Request struct {
-	Pid   string
-	Mid   string
-	Throw models.ThrowType
+	// Pid is the player throwing, it must be the player who is up. Without it the throw goes to whoever is up.
+	Pid   string           `json:"Pid"`
+	Mid   string           `json:"Mid"`
+	Throw models.ThrowType `json:"Throw"`
+	// Version is the version of the match the throw was scored on, zero skips the check.
+	Version int `json:"Version"`
 }
 
 type Response struct {
-	Won            bool
-	NotValid       bool
-	NextThrowBy    string
-	Scores         map[string]int
-	PossibleFinish []models.ThrowType
+	Won            bool                           `json:"Won"`
+	LegWon         bool                           `json:"LegWon"`
+	SetWon         bool                           `json:"SetWon"`
+	NotValid       bool                           `json:"NotValid"`
+	NextThrowBy    string                         `json:"NextThrowBy"`
+	Scores         map[string]int                 `json:"Scores"`
+	PossibleFinish []models.ThrowType             `json:"PossibleFinish"`
+	Marks          map[string]models.CricketMarks `json:"Marks,omitempty"`
+	LegsWon        map[string]int                 `json:"LegsWon"`
+	SetsWon        map[string]int                 `json:"SetsWon"`
+	// Version is the version of the match after the throw, to be sent with the next one.
+	Version int `json:"Version"`
 }
@@ -0,0 +1,2 @@
+// Package rating contains request/response types for the rating endpoint.
+package rating
@@ -0,0 +1,10 @@
+package rating
+
+import "darts-counter/models"
+
+// Response holds the current rating of a player and its rating after every rated match, oldest first.
+type Response struct {
+	Pid     string               `json:"Pid"`
+	Rating  float64              `json:"Rating"`
+	History []models.RatingPoint `json:"History"`
+}
@@ -0,0 +1,2 @@
+// Package undothrow contains request types for the undo throw endpoint.
+package undothrow
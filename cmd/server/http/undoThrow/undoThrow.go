@@ -0,0 +1,8 @@
+package undothrow
+
+// Request represents an undo throw request payload.
+type Request struct {
+	Mid string `json:"Mid"`
+	// Version is the version of the match the undo was asked on, zero skips the check.
+	Version int `json:"Version"`
+}
@@ -4,8 +4,8 @@ import "darts-counter/models"
 
 // Request represents an update player request payload.
 type Request struct {
-	ID   string
-	Name string
+	ID   string `json:"ID"`
+	Name string `json:"Name"`
 }
 
 // Response wraps the updated player entity returned to the client.
@@ -1,20 +1,35 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"slices"
 
+	"darts-counter/cmd/server/config"
 	handler "darts-counter/cmd/server/http"
 	"darts-counter/darts"
 	"darts-counter/response"
 	"darts-counter/storage"
 )
 
-func enableCORS(next http.Handler) http.Handler {
+// enableCORS lets browsers on the given origins call the API, "*" allows every origin.
+func enableCORS(origins []string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		origin := r.Header.Get("Origin")
+		switch {
+		case slices.Contains(origins, "*"):
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(origins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -23,39 +38,44 @@ func enableCORS(next http.Handler) http.Handler {
 	})
 }
 
+// logRequests logs every request at debug level.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Debug("request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func main() {
-	store := storage.NewStorage("darts.db")
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.Level()})))
+	fmt.Fprintf(os.Stderr, "effective configuration:\n%s", cfg)
+
+	var store storage.Repository
+	if cfg.Memory {
+		store = storage.NewMemoryStore()
+	} else {
+		store = storage.NewStorage(cfg.DB)
+	}
 	responseBuilder := response.NewBuilder()
 	service := darts.NewService(store, responseBuilder)
-	api, err := handler.NewApi(store, service)
+	api, err := handler.NewApi(store, service, handler.Options{
+		Assets:        cfg.Assets,
+		MatchDefaults: cfg.MatchDefaults(),
+		CORSOrigins:   cfg.CORSOrigins,
+	})
 	if err != nil {
 		log.Fatal("Api could be initialized")
 	}
 
-	mux := http.NewServeMux()
-
-	// CRUD Player
-	mux.HandleFunc("/createPlayer", api.CreatePlayer)
-	mux.HandleFunc("/updatePlayer", api.UpdatePlayer)
-	mux.HandleFunc("/listPlayers", api.ListPlayers)
-	mux.HandleFunc("/deletePlayer", api.DeletePlayer)
-
-	// CRD Matches
-	mux.HandleFunc("/createMatch", api.CreateMatch)
-	mux.HandleFunc("/listMatches", api.ListMatches)
-	mux.HandleFunc("/deleteMatch", api.DeleteMatch)
-	mux.HandleFunc("/getMatch", api.GetMatch)
-
-	// gameplay
-	mux.HandleFunc("/playerThrow", api.PlayerThrow)
-
-	// misc
-	mux.HandleFunc("/statistics", api.Statistics)
-	// mux.HandleFunc("/settings", api.Settings)
-
-	// media streaming
-	mux.HandleFunc("/streamFile", api.StreamFile)
+	mux := routes(api)
 
-	log.Println("Server started on :8080")
-	log.Fatal(http.ListenAndServe(":8080", enableCORS(mux)))
+	slog.Info("server started", "addr", cfg.Addr)
+	log.Fatal(http.ListenAndServe(cfg.Addr, logRequests(enableCORS(cfg.CORSOrigins, mux))))
 }
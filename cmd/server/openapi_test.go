@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"darts-counter/client"
+	handler "darts-counter/cmd/server/http"
+	apierror "darts-counter/cmd/server/http/apiError"
+	creatematch "darts-counter/cmd/server/http/createMatch"
+	createplayer "darts-counter/cmd/server/http/createPlayer"
+	"darts-counter/cmd/server/http/openapi"
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	"darts-counter/models"
+)
+
+// call is a request of an endpoint of the OpenAPI document.
+type call struct {
+	id, path, body string
+}
+
+// TestOpenAPI_HandlersMatchSpec calls every endpoint of the document and checks that the status and body
+// of the response are the ones the document describes, for a successful call and for an unknown ID.
+func TestOpenAPI_HandlersMatchSpec(t *testing.T) {
+	assets := t.TempDir()
+	if err := os.WriteFile(filepath.Join(assets, "hit.mp3"), []byte("ID3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServerWith(t, handler.Options{Assets: assets})
+	doc := openapi.Spec()
+
+	var p1, p2, p3 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p3"}`, &p3)
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":301}`, &match)
+	do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":60}`, nil)
+	var got struct{ History models.History }
+	do(t, srv, "GET", "/api/v1/matches/"+match.ID, "", &got)
+	throwID := strconv.FormatInt(got.History.History[p1.ID][0].ID, 10)
+
+	// in order, the deleting calls come last
+	calls := []call{
+		{"ListPlayers", "/api/v1/players", ""},
+		{"CreatePlayer", "/api/v1/players", `{"Name":"p4"}`},
+		{"UpdatePlayer", "/api/v1/players/" + p1.ID, `{"Name":"p1b"}`},
+		{"PlayerStatistics", "/api/v1/players/" + p1.ID + "/statistics", ""},
+		{"Rating", "/api/v1/players/" + p1.ID + "/rating", ""},
+		{"HeadToHead", "/api/v1/players/" + p1.ID + "/head-to-head/" + p2.ID, ""},
+		{"Leaderboard", "/api/v1/leaderboard?metric=average&window=all&minMatches=0", ""},
+		{"ListMatches", "/api/v1/matches", ""},
+		{"CreateMatch", "/api/v1/matches", `{"Pids":["` + p1.ID + `","` + p3.ID + `"],"GameType":1}`},
+		{"GetMatch", "/api/v1/matches/" + match.ID, ""},
+		{"MatchStatistics", "/api/v1/matches/" + match.ID + "/statistics", ""},
+		{"PlayerThrow", "/api/v1/matches/" + match.ID + "/throws", `{"Throw":60,"Version":2}`},
+		{"EditThrow", "/api/v1/matches/" + match.ID + "/throws/" + throwID, `{"Throw":57}`},
+		{"UndoThrow", "/api/v1/matches/" + match.ID + "/throws/last", ""},
+		{"MatchEvents", "/api/v1/matches/" + match.ID + "/events", ""},
+		{"MatchFeed", "/api/v1/matches/" + match.ID + "/feed", ""},
+		{"ActiveMatchesFeed", "/api/v1/matches/feed", ""},
+		{"StreamFile", "/api/v1/files/hit.mp3", ""},
+		{"OpenAPI", "/api/v1/openapi.json", ""},
+		{"DeleteMatch", "/api/v1/matches/" + match.ID, ""},
+		{"DeletePlayer", "/api/v1/players/" + p3.ID, ""},
+	}
+
+	endpoints := map[string]openapi.Endpoint{}
+	for _, e := range openapi.Endpoints {
+		endpoints[e.ID] = e
+	}
+	for _, c := range calls {
+		e, ok := endpoints[c.id]
+		if !ok {
+			t.Errorf("%s: not in the document", c.id)
+			continue
+		}
+		delete(endpoints, c.id)
+		op := doc.Paths[e.Path][strings.ToLower(e.Method)]
+		if c.body != "" {
+			checkBody(t, doc, c.id+" request", op.RequestBody.Content["application/json"].Schema, []byte(c.body))
+		}
+
+		switch e.Stream {
+		case "":
+			resp, body := fetch(t, srv.Client(), e.Method, srv.URL+c.path, c.body)
+			checkResponse(t, doc, op, c.id, e.Status(), resp, body)
+		case openapi.WebSocket:
+			conn, resp, err := websocket.Dial(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http")+c.path, nil)
+			if err != nil || resp.StatusCode != e.Status() {
+				t.Errorf("%s: expected a WebSocket, got %v", c.id, err)
+				continue
+			}
+			_ = conn.Close(websocket.StatusNormalClosure, "")
+		default:
+			// streams do not end, the headers are enough
+			ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+			req, _ := http.NewRequestWithContext(ctx, e.Method, srv.URL+c.path, nil)
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Errorf("%s: %v", c.id, err)
+				cancel()
+				continue
+			}
+			if _, ok := op.Responses[strconv.Itoa(resp.StatusCode)]; resp.StatusCode != e.Status() || !ok {
+				t.Errorf("%s: expected %d, got %d", c.id, e.Status(), resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); e.Stream != openapi.AnyContent && !strings.HasPrefix(ct, e.Stream) {
+				t.Errorf("%s: expected content type %s, got %s", c.id, e.Stream, ct)
+			}
+			_ = resp.Body.Close()
+			cancel()
+		}
+	}
+	for id := range endpoints {
+		t.Errorf("%s: not called, add it to the calls of the test", id)
+	}
+
+	// an unknown ID has to be answered with a documented error
+	unknown := uuid.NewString()
+	for _, c := range calls {
+		e := openapi.Endpoints[indexOf(c.id)]
+		if e.Stream != "" || !strings.Contains(e.Path, "{id}") {
+			continue
+		}
+		path := strings.NewReplacer(match.ID, unknown, p1.ID, unknown, p3.ID, unknown).Replace(c.path)
+		resp, body := fetch(t, srv.Client(), e.Method, srv.URL+path, c.body)
+		if resp.StatusCode == http.StatusOK && e.Method == http.MethodDelete {
+			// deleting is idempotent
+			continue
+		}
+		checkResponse(t, doc, doc.Paths[e.Path][strings.ToLower(e.Method)], c.id+" unknown ID", http.StatusNotFound, resp, body)
+	}
+}
+
+func indexOf(id string) int {
+	for i, e := range openapi.Endpoints {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func fetch(t *testing.T, client *http.Client, method, url, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var buf strings.Builder
+	if _, err := io.Copy(&buf, resp.Body); err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return resp, []byte(buf.String())
+}
+
+// checkResponse checks that the response is the expected one and that the document describes its status and body.
+func checkResponse(t *testing.T, doc *openapi.Document, op *openapi.Operation, name string, status int, resp *http.Response, body []byte) {
+	t.Helper()
+	documented, ok := op.Responses[strconv.Itoa(resp.StatusCode)]
+	if resp.StatusCode != status || !ok {
+		t.Errorf("%s: expected %d, got %d %s", name, status, resp.StatusCode, body)
+		return
+	}
+	if media, ok := documented.Content["application/json"]; ok {
+		checkBody(t, doc, name, media.Schema, body)
+	}
+}
+
+func checkBody(t *testing.T, doc *openapi.Document, name string, s *openapi.Schema, body []byte) {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Errorf("%s: %v: %s", name, err, body)
+		return
+	}
+	if err := doc.Validate(s, v); err != nil {
+		t.Errorf("%s: body does not match the document: %v", name, err)
+	}
+}
+
+// TestClient_AgainstRoutes plays a short match through the generated client so that its requests and the
+// decoding of the responses are checked against the real handlers.
+func TestClient_AgainstRoutes(t *testing.T) {
+	c := client.New(newTestServer(t).URL)
+	ctx := t.Context()
+
+	p1, err := c.CreatePlayer(ctx, &createplayer.Request{Name: "p1"})
+	if err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	p2, err := c.CreatePlayer(ctx, &createplayer.Request{Name: "p2"})
+	if err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	if p1.Name != "p1" || p1.ID == "" {
+		t.Errorf("expected the created player, got %+v", p1)
+	}
+
+	match, err := c.CreateMatch(ctx, &creatematch.Request{Pids: []string{p1.ID, p2.ID}, StartAt: 301})
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	thrown, err := c.PlayerThrow(ctx, match.ID, &playerthrow.Request{Throw: models.T20, Version: match.Version})
+	if err != nil {
+		t.Fatalf("throw: %v", err)
+	}
+	if thrown.Scores[p1.ID] != 241 || thrown.NextThrowBy != p1.ID || thrown.Version != match.Version+1 {
+		t.Errorf("expected the T20 to count for p1, got %+v", thrown)
+	}
+
+	got, err := c.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if got.Match.ID != match.ID || got.Match.Scores[p1.ID] != 241 || len(got.History.History[p1.ID]) != 1 ||
+		got.History.History[p1.ID][0].Throw != models.T20 {
+		t.Errorf("expected the match with its throw, got %+v %+v", got.Match, got.History)
+	}
+
+	_, err = c.PlayerThrow(ctx, match.ID, &playerthrow.Request{Throw: models.S1, Version: match.Version})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict || apiErr.Code != apierror.StaleVersion {
+		t.Errorf("expected a stale version error, got %v", err)
+	}
+
+	undone, err := c.UndoThrow(ctx, match.ID, nil)
+	if err != nil || undone.Match.Scores[p1.ID] != 301 {
+		t.Errorf("expected the T20 to be undone without a body, got %+v, %v", undone, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+
+	handler "darts-counter/cmd/server/http"
+	"darts-counter/cmd/server/http/openapi"
+)
+
+// routes registers the endpoints of openapi.Endpoints and the deprecated flat routes they replace.
+// The method of every route is part of its pattern, other methods are answered with 405.
+func routes(api handler.Api) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	handlers := v1Handlers(api)
+	for _, e := range openapi.Endpoints {
+		mux.HandleFunc(e.Method+" "+e.Path, handlers[e.ID])
+	}
+
+	// deprecated flat routes, kept for existing clients
+	legacy := func(pattern, successor string, h http.HandlerFunc) {
+		mux.Handle(pattern, deprecated(successor, h))
+	}
+	legacy("POST /createPlayer", "/api/v1/players", api.CreatePlayer)
+	legacy("POST /updatePlayer", "/api/v1/players/{id}", api.UpdatePlayer)
+	legacy("GET /listPlayers", "/api/v1/players", api.ListPlayers)
+	legacy("DELETE /deletePlayer", "/api/v1/players/{id}", api.DeletePlayer)
+	legacy("POST /createMatch", "/api/v1/matches", api.CreateMatch)
+	legacy("GET /listMatches", "/api/v1/matches", api.ListMatches)
+	legacy("DELETE /deleteMatch", "/api/v1/matches/{id}", api.DeleteMatch)
+	legacy("GET /getMatch", "/api/v1/matches/{id}", api.GetMatch)
+	legacy("POST /playerThrow", "/api/v1/matches/{id}/throws", api.PlayerThrow)
+	legacy("POST /undoThrow", "/api/v1/matches/{id}/throws/last", api.UndoThrow)
+	legacy("POST /editThrow", "/api/v1/matches/{id}/throws/{throwId}", api.EditThrow)
+	legacy("GET /matchEvents", "/api/v1/matches/{id}/events", api.MatchEvents)
+	legacy("GET /matchFeed", "/api/v1/matches/{id}/feed", api.MatchFeed)
+	legacy("GET /activeMatchesFeed", "/api/v1/matches/feed", api.ActiveMatchesFeed)
+	legacy("GET /statistics", "/api/v1/players/{id}/statistics", api.Statistics)
+	legacy("GET /matchStatistics", "/api/v1/matches/{id}/statistics", api.MatchStatistics)
+	legacy("GET /headToHead", "/api/v1/players/{id}/head-to-head/{opponentId}", api.HeadToHead)
+	legacy("GET /rating", "/api/v1/players/{id}/rating", api.Rating)
+	legacy("GET /leaderboard", "/api/v1/leaderboard", api.Leaderboard)
+	legacy("GET /streamFile", "/api/v1/files/{name}", api.StreamFile)
+
+	return mux
+}
+
+// v1Handlers returns the handler of every openapi.Endpoint by ID.
+func v1Handlers(api handler.Api) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"ListPlayers":       api.ListPlayers,
+		"CreatePlayer":      api.CreatePlayer,
+		"UpdatePlayer":      api.UpdatePlayer,
+		"DeletePlayer":      api.DeletePlayer,
+		"PlayerStatistics":  api.Statistics,
+		"Rating":            api.Rating,
+		"HeadToHead":        api.HeadToHead,
+		"Leaderboard":       api.Leaderboard,
+		"ListMatches":       api.ListMatches,
+		"CreateMatch":       api.CreateMatch,
+		"GetMatch":          api.GetMatch,
+		"DeleteMatch":       api.DeleteMatch,
+		"MatchStatistics":   api.MatchStatistics,
+		"PlayerThrow":       api.PlayerThrow,
+		"UndoThrow":         api.UndoThrow,
+		"EditThrow":         api.EditThrow,
+		"MatchEvents":       api.MatchEvents,
+		"MatchFeed":         api.MatchFeed,
+		"ActiveMatchesFeed": api.ActiveMatchesFeed,
+		"StreamFile":        api.StreamFile,
+		"OpenAPI":           openapi.Handler().ServeHTTP,
+	}
+}
+
+// deprecated marks the responses of a flat route as deprecated and links the /api/v1 route replacing it.
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+
+	handler "darts-counter/cmd/server/http"
+	apierror "darts-counter/cmd/server/http/apiError"
+	matchevents "darts-counter/cmd/server/http/matchEvents"
+	"darts-counter/darts"
+	"darts-counter/models"
+	"darts-counter/response"
+	"darts-counter/storage"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWith(t, handler.Options{})
+}
+
+func newTestServerWith(t *testing.T, opts handler.Options) *httptest.Server {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	api, err := handler.NewApi(store, darts.NewService(store, response.NewBuilder()), opts)
+	if err != nil {
+		t.Fatalf("new api: %v", err)
+	}
+	srv := httptest.NewServer(routes(api))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string, out any) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func TestRoutes_RestSurface(t *testing.T) {
+	srv := newTestServer(t)
+
+	var p1, p2 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	var renamed models.Player
+	if do(t, srv, "PATCH", "/api/v1/players/"+p1.ID, `{"Name":"p1b"}`, &renamed); renamed.Name != "p1b" {
+		t.Errorf("expected the player to be renamed, got %+v", renamed)
+	}
+
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":301}`, &match)
+	var thrown struct{ Scores map[string]int }
+	do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":60}`, &thrown)
+	if thrown.Scores[p1.ID] != 241 {
+		t.Errorf("expected the T20 to count for p1, got %v", thrown.Scores)
+	}
+
+	var got struct{ Match models.Match }
+	do(t, srv, "GET", "/api/v1/matches/"+match.ID, "", &got)
+	if got.Match.Scores[p1.ID] != 241 {
+		t.Errorf("expected the match to have the throw, got %+v", got.Match)
+	}
+	if resp := do(t, srv, "DELETE", "/api/v1/matches/"+match.ID+"/throws/last", "", &got); resp.StatusCode != http.StatusOK || got.Match.Scores[p1.ID] != 301 {
+		t.Errorf("expected the throw to be undone, got %d %+v", resp.StatusCode, got.Match)
+	}
+	if resp := do(t, srv, "DELETE", "/api/v1/matches/"+match.ID, "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the match to be deleted, got %d", resp.StatusCode)
+	}
+	if resp := do(t, srv, "GET", "/api/v1/players/"+p1.ID+"/rating", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the rating of p1, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoutes_LegacyAliases(t *testing.T) {
+	srv := newTestServer(t)
+
+	var players []models.Player
+	resp := do(t, srv, "GET", "/listPlayers", "", &players)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") != "true" || !strings.Contains(resp.Header.Get("Link"), "/api/v1/players") {
+		t.Errorf("expected a deprecated alias linking its successor, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if resp := do(t, srv, "GET", "/deleteMatch?matchId=00000000-0000-0000-0000-000000000000", "", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET on a deleting route to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := do(t, srv, "DELETE", "/api/v1/matches", "", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected an unsupported method to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestRoutes_ErrorResponses(t *testing.T) {
+	srv := newTestServer(t)
+
+	var p1, p2 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":40}`, &match)
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		code               apierror.Code
+	}{
+		{"GET", "/api/v1/matches/nope", "", http.StatusBadRequest, apierror.BadRequest},
+		{"GET", "/api/v1/matches/" + p1.ID, "", http.StatusNotFound, apierror.NotFound},
+		{"GET", "/api/v1/players/" + match.ID + "/statistics", "", http.StatusNotFound, apierror.NotFound},
+		{"POST", "/api/v1/matches/" + match.ID + "/throws", `{"Throw":999}`, http.StatusBadRequest, apierror.InvalidThrow},
+		{"POST", "/api/v1/matches/" + match.ID + "/throws", `{"Pid":"` + p2.ID + `","Throw":1}`, http.StatusConflict, apierror.NotYourTurn},
+		{"POST", "/api/v1/matches/" + match.ID + "/throws", `{"Throw":1,"Version":42}`, http.StatusConflict, apierror.StaleVersion},
+		{"DELETE", "/api/v1/matches/" + match.ID + "/throws/last", "", http.StatusBadRequest, apierror.BadRequest},
+		{"POST", "/api/v1/matches/" + match.ID + "/throws", `{"Throw":` + strconv.Itoa(int(models.D20)) + `}`, http.StatusOK, ""},
+		{"POST", "/api/v1/matches/" + match.ID + "/throws", `{"Throw":1}`, http.StatusConflict, apierror.MatchFinished},
+	} {
+		var body apierror.Response
+		resp := do(t, srv, tc.method, tc.path, tc.body, &body)
+		if resp.StatusCode != tc.status || (tc.code != "" && (body.Code != tc.code || body.Message == "")) {
+			t.Errorf("%s %s %s: expected %d %s, got %d %+v", tc.method, tc.path, tc.body, tc.status, tc.code, resp.StatusCode, body)
+		}
+		if tc.code == apierror.StaleVersion && body.Details == nil {
+			t.Errorf("expected a stale version to come with the current match")
+		}
+	}
+}
+
+// sseEvent is an event of a Server-Sent Events feed.
+type sseEvent struct {
+	id, event string
+	data      matchevents.Event
+}
+
+// readSSE reads the next n events of a feed, skipping comments.
+func readSSE(t *testing.T, lines *bufio.Scanner, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var ev sseEvent
+	for len(events) < n && lines.Scan() {
+		key, value, _ := strings.Cut(lines.Text(), ": ")
+		switch key {
+		case "id":
+			ev.id = value
+		case "event":
+			ev.event = value
+		case "data":
+			if err := json.Unmarshal([]byte(value), &ev.data); err != nil {
+				t.Fatalf("data %s: %v", value, err)
+			}
+		case "":
+			if ev.event != "" {
+				events = append(events, ev)
+			}
+			ev = sseEvent{}
+		}
+	}
+	if len(events) < n {
+		t.Fatalf("expected %d events, got %+v: %v", n, events, lines.Err())
+	}
+	return events
+}
+
+func TestRoutes_MatchFeedReplaysAfterLastEventID(t *testing.T) {
+	srv := newTestServer(t)
+
+	var p1, p2 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":301}`, &match)
+	do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":20}`, nil)
+	do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":19}`, nil)
+	var got struct{ History models.History }
+	do(t, srv, "GET", "/api/v1/matches/"+match.ID, "", &got)
+	// latest first
+	first, second := got.History.History[p1.ID][1], got.History.History[p1.ID][0]
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/v1/matches/"+match.ID+"/feed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(first.ID, 10))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("feed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %v", resp.StatusCode, resp.Header)
+	}
+	lines := bufio.NewScanner(resp.Body)
+
+	replay := readSSE(t, lines, 2)
+	if replay[0].event != "throw" || replay[0].id != strconv.FormatInt(second.ID, 10) || replay[0].data.Throw != models.S19 {
+		t.Errorf("expected the missed S19 with its throw ID, got %+v", replay[0])
+	}
+	if replay[1].event != "state" || replay[1].id != "" || replay[1].data.Match.Scores[p1.ID] != 262 {
+		t.Errorf("expected the state after the replay without an ID, got %+v", replay[1])
+	}
+
+	do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":18}`, nil)
+	live := readSSE(t, lines, 2)
+	if live[0].event != "throw" || live[0].data.Throw != models.S18 || live[0].id == "" || live[0].id == replay[0].id {
+		t.Errorf("expected the live S18 with a new throw ID, got %+v", live[0])
+	}
+	if live[1].event != "turn" || live[1].data.Pid != p2.ID {
+		t.Errorf("expected the turn to pass to p2, got %+v", live[1])
+	}
+
+	bad, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/v1/matches/"+match.ID+"/feed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad.Header.Set("Last-Event-ID", "nope")
+	badResp, err := srv.Client().Do(bad)
+	if err != nil {
+		t.Fatalf("feed: %v", err)
+	}
+	_ = badResp.Body.Close()
+	if badResp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid Last-Event-ID to be rejected, got %d", badResp.StatusCode)
+	}
+}
+
+func dialEvents(t *testing.T, srv *httptest.Server, mid string, opts *websocket.DialOptions) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	return websocket.Dial(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/matches/"+mid+"/events", opts)
+}
+
+func readEvents(t *testing.T, conn *websocket.Conn, n int) []matchevents.Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	events := make([]matchevents.Event, n)
+	for i := range events {
+		if err := wsjson.Read(ctx, conn, &events[i]); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+	}
+	return events
+}
+
+func TestRoutes_MatchEventsInOrder(t *testing.T) {
+	srv := newTestServer(t)
+
+	var p1, p2 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":101,"EndMode":2}`, &match)
+
+	if _, resp, err := dialEvents(t, srv, uuid.NewString(), nil); err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an unknown match to be answered with 404, got %v", err)
+	}
+
+	conn, _, err := dialEvents(t, srv, match.ID, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.CloseNow() }()
+	if state := readEvents(t, conn, 1)[0]; state.Type != matchevents.State || state.Match.Scores[p1.ID] != 101 {
+		t.Fatalf("expected the state first, got %+v", state)
+	}
+
+	for _, throw := range []models.ThrowType{models.S20, models.T20, models.T20} { // 21 left, then bust
+		do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":`+strconv.Itoa(int(throw))+`}`, nil)
+	}
+	var got []string
+	for _, ev := range readEvents(t, conn, 5) {
+		got = append(got, string(ev.Type)+" "+ev.Pid)
+	}
+	want := []string{"throw " + p1.ID, "throw " + p1.ID, "throw " + p1.ID, "bust " + p1.ID, "turn " + p2.ID}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// smallBuffers keeps the socket buffers of accepted connections small, so that a client that does not
+// read soon blocks the writes of the server.
+type smallBuffers struct{ net.Listener }
+
+func (l smallBuffers) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if tcp, ok := c.(*net.TCPConn); ok {
+		_ = tcp.SetWriteBuffer(1)
+	}
+	return c, err
+}
+
+func TestRoutes_MatchEventsDropSlowSubscriber(t *testing.T) {
+	store := storage.NewMemoryStore()
+	api, err := handler.NewApi(store, darts.NewService(store, response.NewBuilder()), handler.Options{})
+	if err != nil {
+		t.Fatalf("new api: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(routes(api))
+	srv.Listener = smallBuffers{srv.Listener}
+	srv.Start()
+	defer srv.Close()
+
+	var p1, p2 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":301}`, &match)
+
+	conn, _, err := dialEvents(t, srv, match.ID, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	// misses never end the match, the client reads nothing until all are thrown
+	for range 1000 {
+		do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":`+strconv.Itoa(int(models.MISS))+`}`, nil)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	for {
+		var ev matchevents.Event
+		if err = wsjson.Read(ctx, conn, &ev); err != nil {
+			break
+		}
+	}
+	if status := websocket.CloseStatus(err); status != websocket.StatusTryAgainLater {
+		t.Errorf("expected a slow subscriber to be told to reconnect, got %v", err)
+	}
+}
+
+func TestRoutes_StaleUndoAndEdit(t *testing.T) {
+	srv := newTestServer(t)
+
+	var p1, p2 models.Player
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p1"}`, &p1)
+	do(t, srv, "POST", "/api/v1/players", `{"Name":"p2"}`, &p2)
+	var match models.Match
+	do(t, srv, "POST", "/api/v1/matches", `{"Pids":["`+p1.ID+`","`+p2.ID+`"],"StartAt":301}`, &match)
+	for _, throw := range []string{"20", "19"} {
+		do(t, srv, "POST", "/api/v1/matches/"+match.ID+"/throws", `{"Throw":`+throw+`}`, nil)
+	}
+	var got struct {
+		Match   models.Match
+		History models.History
+	}
+	do(t, srv, "GET", "/api/v1/matches/"+match.ID, "", &got)
+	version := strconv.Itoa(got.Match.Version)
+	// latest first
+	first := strconv.FormatInt(got.History.History[p1.ID][1].ID, 10)
+
+	stale := func(method, path, body string) {
+		t.Helper()
+		var resp struct {
+			Code    apierror.Code `json:"code"`
+			Details struct {
+				Match models.Match `json:"match"`
+			} `json:"details"`
+		}
+		if r := do(t, srv, method, path, body, &resp); r.StatusCode != http.StatusConflict || resp.Code != apierror.StaleVersion ||
+			resp.Details.Match.Version != got.Match.Version || resp.Details.Match.Scores[p1.ID] != 262 {
+			t.Errorf("%s %s %s: expected 409 with the current match, got %d %+v", method, path, body, r.StatusCode, resp)
+		}
+	}
+	stale("DELETE", "/api/v1/matches/"+match.ID+"/throws/last", `{"Version":1}`)
+	stale("PUT", "/api/v1/matches/"+match.ID+"/throws/"+first, `{"Throw":1,"Version":1}`)
+
+	// two tablets undoing on the same version undo one throw
+	statuses := make(chan int, 2)
+	for range 2 {
+		go func() {
+			req, _ := http.NewRequestWithContext(t.Context(), "DELETE", srv.URL+"/api/v1/matches/"+match.ID+"/throws/last", strings.NewReader(`{"Version":`+version+`}`))
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			_ = resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	codes := []int{<-statuses, <-statuses}
+	slices.Sort(codes)
+	if !slices.Equal(codes, []int{http.StatusOK, http.StatusConflict}) {
+		t.Errorf("expected one undo to win and one to be stale, got %v", codes)
+	}
+	do(t, srv, "GET", "/api/v1/matches/"+match.ID, "", &got)
+	if got.Match.Scores[p1.ID] != 281 {
+		t.Errorf("expected only the S19 to be undone, got %v", got.Match.Scores)
+	}
+
+	if r := do(t, srv, "PUT", "/api/v1/matches/"+match.ID+"/throws/"+first, `{"Throw":1,"Version":`+strconv.Itoa(got.Match.Version)+`}`, &got); r.StatusCode != http.StatusOK || got.Match.Scores[p1.ID] != 300 {
+		t.Errorf("expected an edit on the current version to apply, got %d %v", r.StatusCode, got.Match.Scores)
+	}
+	if r := do(t, srv, "DELETE", "/api/v1/matches/"+match.ID+"/throws/last", "", &got); r.StatusCode != http.StatusOK || got.Match.Scores[p1.ID] != 301 {
+		t.Errorf("expected an undo without a version to apply, got %d %v", r.StatusCode, got.Match.Scores)
+	}
+}
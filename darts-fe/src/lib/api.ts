@@ -45,7 +45,8 @@ export function createApi(): ApiClient {
         notify();
 
         if (!res.ok) {
-          const msg = typeof body === "string" ? body : JSON.stringify(body);
+          // errors come as { code, message, details }
+          const msg = typeof body === "string" ? body : body?.message ?? JSON.stringify(body);
           lastError = `${res.status}: ${msg}`;
           notify();
           throw new Error(msg || `HTTP ${res.status}`);
@@ -0,0 +1,98 @@
+package darts
+
+import (
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	models "darts-counter/models"
+)
+
+// Cricket plays standard or cut-throat cricket on 15-20 and bull, depending on the match game type.
+type Cricket struct{}
+
+// StartScore returns zero, cricket counts points up.
+func (Cricket) StartScore(_ int) int {
+	return 0
+}
+
+// Apply adds the marks of the throw for the current player and decides whether they won.
+// Cricket has no busts.
+func (Cricket) Apply(match *models.Match, throw models.ThrowType) Outcome {
+	pid := match.CurrentPlayer
+	applyCricketThrow(match, pid, throw)
+	return Outcome{Won: isCricketWinner(match, pid)}
+}
+
+// NextPlayer passes the turn on after three throws.
+func (Cricket) NextPlayer(match *models.Match, outcome Outcome) string {
+	return nextInRotation(match, outcome)
+}
+
+// Describe reports the marks of every player.
+func (Cricket) Describe(match *models.Match, resp *playerthrow.Response) {
+	resp.Marks = match.Marks
+}
+
+// applyCricketThrow adds the marks of a throw to pid and scores the marks beyond closing a number.
+// In standard cricket the points go to pid as long as any opponent has the number open,
+// in cut-throat cricket they go to every opponent who has not closed the number.
+func applyCricketThrow(match *models.Match, pid string, throw models.ThrowType) {
+	number := throw.Segment()
+	if !models.IsCricketNumber(number) {
+		return
+	}
+
+	marks := match.Marks[pid]
+	if marks == nil {
+		marks = models.CricketMarks{}
+		match.Marks[pid] = marks
+	}
+	total := marks[number] + throw.Multiplier()
+	marks[number] = min(total, models.CricketClosed)
+
+	extra := total - models.CricketClosed
+	if extra <= 0 {
+		return
+	}
+	points := extra * number
+
+	switch match.GameType {
+	case models.Cricket:
+		for _, opponent := range match.Players {
+			if opponent != pid && match.Marks[opponent][number] < models.CricketClosed {
+				match.Scores[pid] += points
+				return
+			}
+		}
+	case models.CutThroatCricket:
+		for _, opponent := range match.Players {
+			if opponent != pid && match.Marks[opponent][number] < models.CricketClosed {
+				match.Scores[opponent] += points
+			}
+		}
+	}
+}
+
+// isCricketWinner reports whether pid has closed every number and leads on points
+// (highest score in standard cricket, lowest in cut-throat).
+func isCricketWinner(match *models.Match, pid string) bool {
+	if !match.Marks[pid].AllClosed() {
+		return false
+	}
+
+	for _, opponent := range match.Players {
+		if opponent == pid {
+			continue
+		}
+		switch match.GameType {
+		case models.Cricket:
+			if match.Scores[opponent] > match.Scores[pid] {
+				return false
+			}
+		case models.CutThroatCricket:
+			if match.Scores[opponent] < match.Scores[pid] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
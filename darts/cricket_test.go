@@ -0,0 +1,113 @@
+package darts
+
+import (
+	"testing"
+
+	"darts-counter/models"
+)
+
+// helper to create a cricket match for the given players
+func newCricketMatch(gameType models.GameType, players ...string) *models.Match {
+	match := &models.Match{
+		ID:            "test-match",
+		Players:       players,
+		GameType:      gameType,
+		CurrentPlayer: players[0],
+		Scores:        map[string]int{},
+		Marks:         map[string]models.CricketMarks{},
+	}
+	for _, pid := range players {
+		match.Scores[pid] = 0
+		match.Marks[pid] = models.CricketMarks{}
+	}
+	return match
+}
+
+func closeAll(marks models.CricketMarks) {
+	for _, n := range models.CricketNumbers {
+		marks[n] = models.CricketClosed
+	}
+}
+
+func TestApplyCricketThrow_MarksAreCappedAndExtraScores(t *testing.T) {
+	match := newCricketMatch(models.Cricket, "p1", "p2")
+
+	applyCricketThrow(match, "p1", models.D20)
+	applyCricketThrow(match, "p1", models.T20)
+
+	if match.Marks["p1"][20] != models.CricketClosed {
+		t.Errorf("expected 20 to be closed, got %d marks", match.Marks["p1"][20])
+	}
+	if match.Scores["p1"] != 40 {
+		t.Errorf("expected 40 points for two extra marks, got %d", match.Scores["p1"])
+	}
+}
+
+func TestApplyCricketThrow_NoPointsWhenClosedByAll(t *testing.T) {
+	match := newCricketMatch(models.Cricket, "p1", "p2")
+	match.Marks["p1"][19] = models.CricketClosed
+	match.Marks["p2"][19] = models.CricketClosed
+
+	applyCricketThrow(match, "p1", models.T19)
+
+	if match.Scores["p1"] != 0 {
+		t.Errorf("expected no points on a number closed by everyone, got %d", match.Scores["p1"])
+	}
+}
+
+func TestApplyCricketThrow_IgnoresNumbersOutOfPlay(t *testing.T) {
+	match := newCricketMatch(models.Cricket, "p1", "p2")
+
+	applyCricketThrow(match, "p1", models.T14)
+
+	if len(match.Marks["p1"]) != 0 || match.Scores["p1"] != 0 {
+		t.Errorf("expected no marks or points for 14, got %v / %d", match.Marks["p1"], match.Scores["p1"])
+	}
+}
+
+func TestApplyCricketThrow_BullCountsAsTwentyFive(t *testing.T) {
+	match := newCricketMatch(models.Cricket, "p1", "p2")
+	match.Marks["p1"][models.CricketBull] = 2
+
+	applyCricketThrow(match, "p1", models.BULL)
+
+	if match.Scores["p1"] != 25 {
+		t.Errorf("expected 25 points for one extra bull mark, got %d", match.Scores["p1"])
+	}
+}
+
+func TestApplyCricketThrow_CutThroatScoresOpenOpponents(t *testing.T) {
+	match := newCricketMatch(models.CutThroatCricket, "p1", "p2", "p3")
+	match.Marks["p1"][18] = models.CricketClosed
+	match.Marks["p3"][18] = models.CricketClosed
+
+	applyCricketThrow(match, "p1", models.D18)
+
+	if match.Scores["p1"] != 0 || match.Scores["p3"] != 0 {
+		t.Errorf("expected thrower and closed opponent to score nothing, got %v", match.Scores)
+	}
+	if match.Scores["p2"] != 36 {
+		t.Errorf("expected 36 points for the open opponent, got %d", match.Scores["p2"])
+	}
+}
+
+func TestIsCricketWinner(t *testing.T) {
+	match := newCricketMatch(models.Cricket, "p1", "p2")
+	closeAll(match.Marks["p1"])
+	match.Scores["p1"] = 40
+	match.Scores["p2"] = 60
+
+	if isCricketWinner(match, "p1") {
+		t.Errorf("expected no standard win while trailing on points")
+	}
+
+	match.GameType = models.CutThroatCricket
+	if !isCricketWinner(match, "p1") {
+		t.Errorf("expected cut-throat win with all closed and the lowest score")
+	}
+
+	match.Marks["p1"][models.CricketBull] = 2
+	if isCricketWinner(match, "p1") {
+		t.Errorf("expected no win with the bull still open")
+	}
+}
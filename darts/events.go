@@ -0,0 +1,167 @@
+package darts
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	matchevents "darts-counter/cmd/server/http/matchEvents"
+	models "darts-counter/models"
+	storage "darts-counter/storage"
+)
+
+// eventBuffer is the number of events a subscriber may fall behind before it is dropped.
+const eventBuffer = 64
+
+// Events fans the changes of matches out to their subscribers.
+type Events struct {
+	mu   sync.Mutex
+	subs map[string]map[chan matchevents.Event]struct{}
+}
+
+func newEvents() *Events {
+	return &Events{subs: map[string]map[chan matchevents.Event]struct{}{}}
+}
+
+// Subscribe returns the events of the match mid from now on and the function ending the subscription.
+// With an empty mid it returns the events of every match.
+// The channel is closed when the subscription ends, or early if the subscriber falls too far behind;
+// it then has to subscribe again and reload the match.
+func (e *Events) Subscribe(mid string) (<-chan matchevents.Event, func()) {
+	ch := make(chan matchevents.Event, eventBuffer)
+	e.mu.Lock()
+	if e.subs[mid] == nil {
+		e.subs[mid] = map[chan matchevents.Event]struct{}{}
+	}
+	e.subs[mid][ch] = struct{}{}
+	e.mu.Unlock()
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.drop(mid, ch)
+	}
+}
+
+// drop ends a subscription if it is still active. e.mu must be held.
+func (e *Events) drop(mid string, ch chan matchevents.Event) {
+	subs := e.subs[mid]
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(e.subs, mid)
+	}
+}
+
+// publish sends events of the match mid to its subscribers without waiting for them.
+func (e *Events) publish(mid string, events ...matchevents.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, key := range []string{mid, ""} {
+		for ch := range e.subs[key] {
+			for _, ev := range events {
+				if !trySend(ch, ev) {
+					e.drop(key, ch)
+					break
+				}
+			}
+		}
+	}
+}
+
+func trySend(ch chan matchevents.Event, ev matchevents.Event) bool {
+	select {
+	case ch <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+// throwEvents returns the events of a throw of thrower that was applied to match and recorded as record.
+func throwEvents(match *models.Match, thrower string, record *storage.ThrowRecord, outcome Outcome) []matchevents.Event {
+	event := func(typ matchevents.Type, pid string) matchevents.Event {
+		return matchevents.Event{Type: typ, Mid: match.ID, Version: match.Version, Pid: pid, Match: match}
+	}
+
+	throw := event(matchevents.Throw, thrower)
+	throw.ThrowID = record.ID
+	throw.Throw = models.ThrowType(record.ThrowType)
+	events := []matchevents.Event{throw}
+	if outcome.Bust {
+		events = append(events, event(matchevents.Bust, thrower))
+	}
+	if outcome.Won {
+		events = append(events, event(matchevents.Leg, thrower))
+	}
+	if outcome.MatchWon {
+		return append(events, event(matchevents.Won, thrower))
+	}
+	if record.EndedTurn {
+		events = append(events, event(matchevents.Turn, match.CurrentPlayer))
+	}
+	return events
+}
+
+// Snapshot returns a state event for the match mid, or for every active match if mid is empty.
+// They are preceded by a throw event, oldest first, for every throw of those matches recorded after the
+// throw with ID after, so that a client that lost its connection can catch up. Without mid, a match that
+// finished after that throw is caught up on as well, so that the client sees its final state. Replayed
+// throw events carry no version and no match, the state events that follow them do. Both are read in one
+// transaction.
+func (s *Service) Snapshot(ctx context.Context, mid string, after int64) ([]matchevents.Event, error) {
+	var missed, states []matchevents.Event
+	err := s.inTx(ctx, func(ctx context.Context, tx *Service) error {
+		var matches []*models.Match
+		if mid != "" {
+			match, err := tx.Store.GetMatch(ctx, mid)
+			if err != nil {
+				return err
+			}
+			matches = []*models.Match{match}
+		} else {
+			all, err := tx.Store.GetMatches(ctx)
+			if err != nil {
+				return err
+			}
+			// finished matches only matter to a client catching up
+			for _, match := range all {
+				if match.WonBy == "" || after > 0 {
+					matches = append(matches, match)
+				}
+			}
+		}
+
+		for _, match := range matches {
+			var throws []*storage.ThrowRecord
+			if after > 0 {
+				all, err := tx.Store.GetThrows(ctx, match.ID)
+				if err != nil {
+					return err
+				}
+				for _, t := range all {
+					if t.ID > after {
+						throws = append(throws, t)
+					}
+				}
+			}
+			if mid == "" && match.WonBy != "" && len(throws) == 0 {
+				continue
+			}
+			for _, t := range throws {
+				missed = append(missed, matchevents.Event{Type: matchevents.Throw, Mid: t.Mid, Pid: t.Pid, ThrowID: t.ID, Throw: models.ThrowType(t.ThrowType)})
+			}
+			states = append(states, matchevents.Event{Type: matchevents.State, Mid: match.ID, Version: match.Version, Match: match})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(missed, func(a, b matchevents.Event) int { return cmp.Compare(a.ThrowID, b.ThrowID) })
+	return append(missed, states...), nil
+}
@@ -0,0 +1,66 @@
+package darts
+
+import (
+	"context"
+	"fmt"
+
+	headtohead "darts-counter/cmd/server/http/headToHead"
+	models "darts-counter/models"
+)
+
+// HeadToHead returns the record between two players over the matches they played against each other.
+func (s *Service) HeadToHead(ctx context.Context, pid, opponentPid string) (*headtohead.Response, error) {
+	if pid == opponentPid {
+		return nil, fmt.Errorf("%w: head-to-head needs two different players", ErrInvalidRequest)
+	}
+	player, err := s.Store.GetPlayer(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	opponent, err := s.Store.GetPlayer(ctx, opponentPid)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.Store.GetSharedMatches(ctx, pid, opponentPid)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &headtohead.Response{
+		Played:   len(matches),
+		Player:   headtohead.Record{Player: player},
+		Opponent: headtohead.Record{Player: opponent},
+		Matches:  matches,
+	}
+	var playerTally, opponentTally x01Tally
+	for _, match := range matches {
+		switch match.WonBy {
+		case "":
+		case pid:
+			resp.Finished++
+			resp.Player.Wins++
+		case opponentPid:
+			resp.Finished++
+			resp.Opponent.Wins++
+		default:
+			resp.Finished++
+		}
+
+		if match.GameType != models.X01 {
+			continue
+		}
+		throws, err := s.Store.GetThrows(ctx, match.ID)
+		if err != nil {
+			return nil, err
+		}
+		playerTally.addMatch(match, throws, pid)
+		opponentTally.addMatch(match, throws, opponentPid)
+	}
+
+	resp.Player.ThreeDartAverage = playerTally.stats().ThreeDartAverage
+	resp.Player.HighestCheckout = playerTally.highestFinish
+	resp.Opponent.ThreeDartAverage = opponentTally.stats().ThreeDartAverage
+	resp.Opponent.HighestCheckout = opponentTally.highestFinish
+
+	return resp, nil
+}
@@ -0,0 +1,147 @@
+package darts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	leaderboard "darts-counter/cmd/server/http/leaderboard"
+	models "darts-counter/models"
+)
+
+// Leaderboard ranks every player with at least req.MinMatches finished matches in the time window by the requested metric.
+func (s *Service) Leaderboard(ctx context.Context, req *leaderboard.Request) (*leaderboard.Response, error) {
+	metric := req.Metric
+	if metric == "" {
+		metric = leaderboard.WinRate
+	}
+	value, err := leaderboardMetric(metric)
+	if err != nil {
+		return nil, err
+	}
+	window := req.Window
+	if window == "" {
+		window = leaderboard.AllTime
+	}
+	since, err := windowStart(window, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := s.Store.GetPlayers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.Store.GetAllPlayerStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.Store.GetFinishedMatches(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*leaderboard.Entry, len(players))
+	for _, p := range players {
+		entries[p.ID] = &leaderboard.Entry{Player: p}
+	}
+	for _, ps := range stats {
+		if e, ok := entries[ps.Pid]; ok {
+			e.Rating = ps.Rating
+		}
+	}
+
+	tallies := make(map[string]*x01Tally, len(players))
+	for _, match := range matches {
+		throws, err := s.Store.GetThrows(ctx, match.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, pid := range match.Players {
+			e, ok := entries[pid]
+			if !ok {
+				continue
+			}
+			e.Matches++
+			if match.WonBy == pid {
+				e.Wins++
+			}
+			if match.GameType == models.X01 {
+				if tallies[pid] == nil {
+					tallies[pid] = &x01Tally{}
+				}
+				tallies[pid].addMatch(match, throws, pid)
+			}
+		}
+	}
+
+	resp := &leaderboard.Response{Metric: metric, Window: window, Entries: []leaderboard.Entry{}}
+	for _, p := range players {
+		e := entries[p.ID]
+		if e.Matches < req.MinMatches {
+			continue
+		}
+		if e.Matches > 0 {
+			e.WinRate = float32(e.Wins) / float32(e.Matches)
+		}
+		if t := tallies[p.ID]; t != nil {
+			x01 := t.stats()
+			e.ThreeDartAverage = x01.ThreeDartAverage
+			e.CheckoutPercentage = x01.CheckoutPercentage
+			e.OneEighties = x01.OneEighties
+		}
+		resp.Entries = append(resp.Entries, *e)
+	}
+
+	// ties go to the player with more matches, then by name
+	sort.SliceStable(resp.Entries, func(i, j int) bool {
+		a, b := &resp.Entries[i], &resp.Entries[j]
+		if value(a) != value(b) {
+			return value(a) > value(b)
+		}
+		if a.Matches != b.Matches {
+			return a.Matches > b.Matches
+		}
+		return a.Player.Name < b.Player.Name
+	})
+	for i := range resp.Entries {
+		if i > 0 && value(&resp.Entries[i]) == value(&resp.Entries[i-1]) {
+			resp.Entries[i].Rank = resp.Entries[i-1].Rank
+		} else {
+			resp.Entries[i].Rank = i + 1
+		}
+	}
+
+	return resp, nil
+}
+
+// leaderboardMetric returns the value of an entry players are ranked by for metric, higher is better.
+func leaderboardMetric(metric string) (func(*leaderboard.Entry) float64, error) {
+	switch metric {
+	case leaderboard.WinRate:
+		return func(e *leaderboard.Entry) float64 { return float64(e.WinRate) }, nil
+	case leaderboard.ThreeDartAverage:
+		return func(e *leaderboard.Entry) float64 { return float64(e.ThreeDartAverage) }, nil
+	case leaderboard.Checkout:
+		return func(e *leaderboard.Entry) float64 { return float64(e.CheckoutPercentage) }, nil
+	case leaderboard.OneEighties:
+		return func(e *leaderboard.Entry) float64 { return float64(e.OneEighties) }, nil
+	case leaderboard.Rating:
+		return func(e *leaderboard.Entry) float64 { return e.Rating }, nil
+	}
+	return nil, fmt.Errorf("%w: unknown leaderboard metric %q", ErrInvalidRequest, metric)
+}
+
+// windowStart returns the earliest finish time of a match in window, zero for all time.
+func windowStart(window string, now time.Time) (time.Time, error) {
+	switch window {
+	case leaderboard.LastWeek:
+		return now.AddDate(0, 0, -7), nil
+	case leaderboard.LastMonth:
+		return now.AddDate(0, 0, -30), nil
+	case leaderboard.AllTime:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: unknown leaderboard window %q", ErrInvalidRequest, window)
+}
@@ -0,0 +1,42 @@
+package darts
+
+import "sync"
+
+// matchLocks serializes the changes to a match within the service.
+type matchLocks struct {
+	mu    sync.Mutex
+	locks map[string]*matchLock
+}
+
+type matchLock struct {
+	sync.Mutex
+	// waiters counts the holder and everyone waiting, the lock is dropped when it reaches zero
+	waiters int
+}
+
+func newMatchLocks() *matchLocks {
+	return &matchLocks{locks: map[string]*matchLock{}}
+}
+
+// lock blocks until no other change to the match mid is in progress and returns the function releasing it.
+func (l *matchLocks) lock(mid string) func() {
+	l.mu.Lock()
+	ml, ok := l.locks[mid]
+	if !ok {
+		ml = &matchLock{}
+		l.locks[mid] = ml
+	}
+	ml.waiters++
+	l.mu.Unlock()
+
+	ml.Lock()
+	return func() {
+		ml.Unlock()
+		l.mu.Lock()
+		ml.waiters--
+		if ml.waiters == 0 {
+			delete(l.locks, mid)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,23 @@
+package darts
+
+import (
+	"context"
+	rating "darts-counter/cmd/server/http/rating"
+)
+
+// Rating returns the current rating of a player and how it developed over the rated matches.
+func (s *Service) Rating(ctx context.Context, pid string) (*rating.Response, error) {
+	if _, err := s.Store.GetPlayer(ctx, pid); err != nil {
+		return nil, err
+	}
+	stats, err := s.Store.GetPlayerStats(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.Store.GetRatingHistory(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rating.Response{Pid: pid, Rating: stats.Rating, History: history}, nil
+}
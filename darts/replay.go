@@ -0,0 +1,236 @@
+package darts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	matchevents "darts-counter/cmd/server/http/matchEvents"
+	models "darts-counter/models"
+	storage "darts-counter/storage"
+)
+
+// EditThrow corrects a recorded throw of a match and recomputes the match from all of its throws.
+// Throws recorded after a throw that now wins the match are discarded. The edit and the replay share a transaction,
+// an Edit event is published once both are stored. Like PlayerThrow, a version other than zero that is not the
+// current version of the match fails with a StaleVersionError.
+func (s *Service) EditThrow(ctx context.Context, mid string, throwID int64, throw models.ThrowType, version int) (*models.Match, error) {
+	if !isValidThrow(throw) {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidThrow, throw)
+	}
+
+	unlock := s.locks.lock(mid)
+	defer unlock()
+
+	var match *models.Match
+	var thrower string
+	err := s.inTx(ctx, func(ctx context.Context, tx *Service) error {
+		current, err := tx.Store.GetMatch(ctx, mid)
+		if err != nil {
+			return fmt.Errorf("match %s: %w", mid, err)
+		}
+		if err := checkVersion(current, version); err != nil {
+			return err
+		}
+		record, err := tx.Store.GetThrow(ctx, throwID)
+		if err == nil && record.Mid != mid {
+			err = storage.ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("throw %d of match %s: %w", throwID, mid, err)
+		}
+		thrower = record.Pid
+		record.ThrowType = int(throw)
+		if _, err := tx.Store.UpdateThrow(ctx, record); err != nil {
+			return err
+		}
+
+		match, err = tx.replayMatch(ctx, mid)
+		return err
+	})
+	if err != nil {
+		return nil, s.staleVersion(ctx, mid, version, err)
+	}
+	s.Events.publish(mid, matchevents.Event{Type: matchevents.Edit, Mid: mid, Version: match.Version, Pid: thrower, ThrowID: throwID, Throw: throw, Match: match})
+
+	return match, nil
+}
+
+// UndoThrow removes the latest throw of a match and rolls the match back to the state before it,
+// re-opening the match if that throw had won it, and publishes an Undo event. A version other than zero
+// that is not the current version of the match fails with a StaleVersionError, so that two clients undoing
+// at the same time undo one throw, not two.
+func (s *Service) UndoThrow(ctx context.Context, mid string, version int) (*models.Match, error) {
+	unlock := s.locks.lock(mid)
+	defer unlock()
+
+	var match *models.Match
+	var last *storage.ThrowRecord
+	err := s.inTx(ctx, func(ctx context.Context, tx *Service) error {
+		current, err := tx.Store.GetMatch(ctx, mid)
+		if err != nil {
+			return fmt.Errorf("match %s: %w", mid, err)
+		}
+		if err := checkVersion(current, version); err != nil {
+			return err
+		}
+		last, err = tx.Store.GetLastThrow(ctx, mid)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: no throw to undo", ErrInvalidRequest)
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Store.DeleteThrow(ctx, last.ID); err != nil {
+			return err
+		}
+
+		match, err = tx.replayMatch(ctx, mid)
+		return err
+	})
+	if err != nil {
+		return nil, s.staleVersion(ctx, mid, version, err)
+	}
+	s.Events.publish(mid, matchevents.Event{Type: matchevents.Undo, Mid: mid, Version: match.Version, Pid: last.Pid, ThrowID: last.ID, Throw: models.ThrowType(last.ThrowType), Match: match})
+
+	return match, nil
+}
+
+// replayMatch recomputes scores, turn and winner of a match from its recorded throws and persists them.
+func (s *Service) replayMatch(ctx context.Context, mid string) (*models.Match, error) {
+	match, err := s.Store.GetMatch(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := RulesFor(match.GameType)
+	if err != nil {
+		return nil, err
+	}
+	throws, err := s.Store.GetThrows(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+	matchPlayers, err := s.Store.GetAllMatchPlayers(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make([]storage.ThrowRecord, len(throws))
+	for i, tr := range throws {
+		recorded[i] = *tr
+	}
+
+	wasWon := match.WonBy != ""
+	counted := replay(match, matchPlayers, rules, throws)
+
+	for i, tr := range throws {
+		if i >= counted {
+			// the match was already won before this throw
+			if err := s.Store.DeleteThrow(ctx, tr.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if *tr == recorded[i] {
+			continue
+		}
+		if _, err := s.Store.UpdateThrow(ctx, tr); err != nil {
+			return nil, err
+		}
+	}
+	for _, mp := range matchPlayers {
+		if _, err := s.Store.UpdateMatchPlayer(ctx, mp); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Store.UpdateMatch(ctx, match); err != nil {
+		return nil, err
+	}
+	switch {
+	case wasWon && match.WonBy == "":
+		err = s.Store.ReopenMatch(ctx, mid)
+	case match.WonBy != "":
+		err = s.Store.WonMatch(ctx, match)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return match, nil
+}
+
+// replay resets match and its players to the start of the match and applies throws in order,
+// recomputing the turn, leg, ended turn and bust flags of every throw. Replaying stops at the throw
+// that won the match; it returns the number of throws that were applied.
+func replay(match *models.Match, matchPlayers []*models.MatchPlayer, rules GameRules, throws []*storage.ThrowRecord) int {
+	byPid := make(map[string]*models.MatchPlayer, len(matchPlayers))
+	for _, mp := range matchPlayers {
+		byPid[mp.Pid] = mp
+		mp.OverallThrows = 0
+		mp.Score = match.StartAt
+		mp.TurnStartScore = match.StartAt
+		match.Scores[mp.Pid] = match.StartAt
+		if match.Marks != nil {
+			mp.Marks = models.CricketMarks{}
+			match.Marks[mp.Pid] = mp.Marks
+		}
+	}
+	match.CurrentThrow = 0
+	match.WonBy = ""
+	match.Leg = 0
+	for _, mp := range matchPlayers {
+		match.LegsWon[mp.Pid] = 0
+		match.SetsWon[mp.Pid] = 0
+	}
+	if len(match.Players) > 0 {
+		match.CurrentPlayer = match.Players[0]
+	}
+	defer func() {
+		for _, mp := range matchPlayers {
+			syncMatchPlayer(match, mp)
+		}
+	}()
+
+	endedTurns := make(map[string]int, len(matchPlayers))
+	var turn []*storage.ThrowRecord
+	var prev *storage.ThrowRecord
+	for i, tr := range throws {
+		thrower, ok := byPid[tr.Pid]
+		if !ok {
+			continue
+		}
+		if tr.Pid != match.CurrentPlayer {
+			// a correction ended the previous turn early, the recorded thrower starts a new turn
+			if prev != nil && !prev.EndedTurn {
+				prev.EndedTurn = true
+				endedTurns[prev.Pid]++
+			}
+			match.CurrentPlayer = tr.Pid
+			match.CurrentThrow = 0
+		}
+		if match.CurrentThrow == 0 {
+			turn = turn[:0]
+		}
+		prev = tr
+
+		tr.Leg = match.Leg
+		outcome := applyThrow(match, thrower, rules, models.ThrowType(tr.ThrowType))
+		tr.Turn = endedTurns[tr.Pid] + 1
+		tr.EndedTurn = outcome.Bust || outcome.Won || match.CurrentThrow == 0
+		tr.Busted = false
+		turn = append(turn, tr)
+		if outcome.Bust {
+			for _, t := range turn {
+				t.Busted = true
+			}
+		}
+		if tr.EndedTurn {
+			endedTurns[tr.Pid]++
+		}
+		if outcome.MatchWon {
+			return i + 1
+		}
+	}
+
+	return len(throws)
+}
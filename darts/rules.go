@@ -0,0 +1,69 @@
+package darts
+
+import (
+	"fmt"
+	"sync"
+
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	models "darts-counter/models"
+)
+
+// Outcome is the result of applying a single throw to a match.
+type Outcome struct {
+	// Bust marks a throw that is not valid under the rules and ends the turn.
+	Bust bool
+	// Won marks a throw that finished the leg for the thrower.
+	Won bool
+	// SetWon and MatchWon are set by the Service when the leg also decided the set or the match.
+	SetWon   bool
+	MatchWon bool
+}
+
+// GameRules implements the scoring logic of a game type.
+type GameRules interface {
+	// StartScore returns the score every player starts with for the requested StartAt.
+	StartScore(startAt int) int
+	// Apply scores the throw for match.CurrentPlayer, updating the match scores in place,
+	// and decides whether the throw busted the turn or won the match.
+	Apply(match *models.Match, throw models.ThrowType) Outcome
+	// NextPlayer returns who throws next after a throw with the given outcome was recorded.
+	// match.CurrentThrow already accounts for the recorded throw.
+	NextPlayer(match *models.Match, outcome Outcome) string
+	// Describe adds the rule specific state (possible finishes, board) to a throw response.
+	Describe(match *models.Match, resp *playerthrow.Response)
+}
+
+var (
+	rulesMu  sync.RWMutex
+	registry = map[models.GameType]GameRules{
+		models.X01:              X01{},
+		models.Cricket:          Cricket{},
+		models.CutThroatCricket: Cricket{},
+	}
+)
+
+// RegisterRules registers the rules for a game type, replacing any rules registered before.
+func RegisterRules(gameType models.GameType, rules GameRules) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	registry[gameType] = rules
+}
+
+// RulesFor returns the rules registered for a game type.
+func RulesFor(gameType models.GameType) (GameRules, error) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	rules, ok := registry[gameType]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown game type %d", ErrInvalidRequest, gameType)
+	}
+	return rules, nil
+}
+
+// nextInRotation passes the turn to the next player after three throws or a bust.
+func nextInRotation(match *models.Match, outcome Outcome) string {
+	if outcome.Bust || match.CurrentThrow == 0 {
+		return match.GetNextPlayer()
+	}
+	return match.CurrentPlayer
+}
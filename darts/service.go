@@ -1,10 +1,13 @@
 package darts
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 
-	playerstats "darts-counter/cmd/server/http/playerStats"
+	creatematch "darts-counter/cmd/server/http/createMatch"
+	matchevents "darts-counter/cmd/server/http/matchEvents"
 	playerthrow "darts-counter/cmd/server/http/playerThrow"
 	models "darts-counter/models"
 	response "darts-counter/response"
@@ -13,91 +16,183 @@ import (
 
 // Service is the service for the darts business logic.
 type Service struct {
-	Store    *storage.Storage
+	Store    storage.Repository
 	Response response.Builder
+	// Events publishes every change the service makes to a match once it is stored.
+	Events *Events
+	locks  *matchLocks
 }
 
 // NewService creates a new darts Service.
-func NewService(store *storage.Storage, resposneBuilder response.Builder) *Service {
+func NewService(store storage.Repository, resposneBuilder response.Builder) *Service {
 	if store == nil || resposneBuilder == nil {
 		log.Fatal("store or responseBuilder is nil")
 	}
 	return &Service{
 		Store:    store,
 		Response: resposneBuilder,
+		Events:   newEvents(),
+		locks:    newMatchLocks(),
 	}
 }
 
-// CollectStats aggregates statistics for the given player ID.
-func (s *Service) CollectStats(_ string) (*playerstats.Response, error) {
-	return nil, errors.New("error")
+// CreateMatch creates a match with the game type, start score, In/Out modes and legs/sets format of the request.
+// Without a legs or sets target a single leg decides the match.
+func (s *Service) CreateMatch(ctx context.Context, req *creatematch.Request) (*models.Match, error) {
+	rules, err := RulesFor(req.GameType)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Store.CreateMatch(ctx, req.Pids, req.GameType, rules.StartScore(req.StartAt), req.StartMode, req.EndMode, max(req.Legs, 1), max(req.Sets, 1))
+}
+
+var (
+	// ErrNotYourTurn is returned when a player throws while another player is up.
+	ErrNotYourTurn = errors.New("not your turn")
+	// ErrMatchFinished is returned for a throw into a match that has been won.
+	ErrMatchFinished = errors.New("match is finished")
+	// ErrInvalidThrow is returned for a throw that is not on the board.
+	ErrInvalidThrow = errors.New("invalid throw")
+	// ErrInvalidRequest is returned for a request the service cannot carry out as asked,
+	// e.g. an unknown game type or undoing a throw of a match without any.
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// StaleVersionError is returned when a change was made on an outdated version of a match.
+// Match is the current state of the match.
+type StaleVersionError struct {
+	Version int
+	Match   *models.Match
+}
+
+func (e *StaleVersionError) Error() string {
+	return fmt.Sprintf("match version %d is outdated, the current version is %d", e.Version, e.Match.Version)
+}
+
+// Unwrap lets errors.Is match storage.ErrVersionConflict.
+func (e *StaleVersionError) Unwrap() error {
+	return storage.ErrVersionConflict
 }
 
 // PlayerThrow processes a player's throw in a match and returns the updated state.
-func (s *Service) PlayerThrow(req *playerthrow.Request) (*playerthrow.Response, error) {
+// Throws to the same match are applied one after another, each in a single transaction, so either
+// all of its changes are stored or none. A throw with a Version other than the current version of
+// the match fails with a StaleVersionError; without a Version the check is skipped.
+// A throw by anyone but the current player fails with ErrNotYourTurn. Without a Pid the throw goes to
+// whoever is up, for a single scorer entering the throws of all players. A throw into a match that does not
+// exist fails with storage.ErrNotFound, into a finished one with ErrMatchFinished.
+// The events of the throw are published once it is stored.
+func (s *Service) PlayerThrow(ctx context.Context, req *playerthrow.Request) (*playerthrow.Response, error) {
 	if !isValidThrow(req.Throw) {
-		return nil, errors.New("invalid throw")
+		return nil, fmt.Errorf("%w: %d", ErrInvalidThrow, req.Throw)
 	}
 
-	mid := req.Mid
-	pid := req.Pid
+	unlock := s.locks.lock(req.Mid)
+	defer unlock()
 
-	match, err := s.Store.GetActiveMatch(mid)
+	var resp *playerthrow.Response
+	var events []matchevents.Event
+	err := s.inTx(ctx, func(ctx context.Context, tx *Service) error {
+		var err error
+		resp, events, err = tx.playerThrow(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.New("error getting match or match is not active")
+		return nil, s.staleVersion(ctx, req.Mid, req.Version, err)
 	}
-	matchPlayerModel, err := s.Store.GetMatchPlayerModel(mid, pid)
-	if err != nil {
-		return nil, err
+	s.Events.publish(req.Mid, events...)
+
+	return resp, nil
+}
+
+// checkVersion fails with a StaleVersionError if version is set and not the current version of match.
+func checkVersion(match *models.Match, version int) error {
+	if version != 0 && version != match.Version {
+		return &StaleVersionError{Version: version, Match: match}
+	}
+	return nil
+}
+
+// staleVersion turns a version conflict of the storage into a StaleVersionError with the current state of the match.
+func (s *Service) staleVersion(ctx context.Context, mid string, version int, err error) error {
+	var stale *StaleVersionError
+	if errors.As(err, &stale) || !errors.Is(err, storage.ErrVersionConflict) {
+		return err
 	}
+	match, gerr := s.Store.GetMatch(ctx, mid)
+	if gerr != nil {
+		return err
+	}
+	return &StaleVersionError{Version: version, Match: match}
+}
 
-	if matchPlayerModel.Score == match.StartAt { // is IN
-		if !isValidIn(models.MapNumberToIO(match.StartMode), matchPlayerModel.Score, req.Throw) {
-			// not a valid start, the turn is over, and it's the next players turn
-			updatedMatch := s.persistTurnOver(match, req.Throw)
+func (s *Service) playerThrow(ctx context.Context, req *playerthrow.Request) (*playerthrow.Response, []matchevents.Event, error) {
+	mid := req.Mid
+	pid := req.Pid
 
-			return s.Response.BuildPlayerThrowResponse(updatedMatch, false, true), nil
+	match, err := s.activeMatch(ctx, mid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkVersion(match, req.Version); err != nil {
+		return nil, nil, err
+	}
+	if pid != "" {
+		if _, err := s.Store.GetMatchPlayerModel(ctx, mid, pid); err != nil {
+			return nil, nil, fmt.Errorf("player %s of match %s: %w", pid, mid, err)
 		}
-
-		updatedMatch, _, err := s.persistThrow(match, matchPlayerModel, &req.Throw)
-		if err != nil {
-			return nil, err
+		if pid != match.CurrentPlayer {
+			return nil, nil, fmt.Errorf("%w: %s is up", ErrNotYourTurn, match.CurrentPlayer)
 		}
-
-		return s.Response.BuildPlayerThrowResponse(updatedMatch, false, false), nil
 	}
 
-	if matchPlayerModel.Score-req.Throw.ToPoints() == 0 { // is OUT
-		if !isValidOut(models.MapNumberToIO(match.EndMode), matchPlayerModel.Score, req.Throw) {
-			// build not valid out response
-			updatedMatch := s.persistTurnOver(match, req.Throw)
-			return s.Response.BuildPlayerThrowResponse(updatedMatch, false, true), nil
-		}
+	rules, err := RulesFor(match.GameType)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// valid finish, the player has won the game
-		updatedMatch, _, err := s.persistThrow(match, matchPlayerModel, &req.Throw)
-		if err != nil {
-			return nil, err
-		}
-		// add a win to the player stats
+	thrower, err := s.Store.GetMatchPlayerModel(ctx, mid, match.CurrentPlayer)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		return s.Response.BuildPlayerThrowResponse(updatedMatch, true, false), nil
+	record := storage.ThrowRecord{Mid: match.ID, Pid: thrower.Pid, ThrowType: int(req.Throw), Leg: match.Leg}
+	outcome := applyThrow(match, thrower, rules, req.Throw)
+	created, err := s.persistThrow(ctx, match, thrower, outcome, record)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if isOverthrow(*match, matchPlayerModel.Score, req.Throw) {
-		updatedMatch := s.persistTurnOver(match, req.Throw)
+	resp := s.Response.BuildPlayerThrowResponse(match, outcome.MatchWon, outcome.Bust)
+	resp.LegWon = outcome.Won
+	resp.SetWon = outcome.SetWon
+	rules.Describe(match, resp)
+
+	return resp, throwEvents(match, thrower.Pid, created, outcome), nil
+}
 
-		return s.Response.BuildPlayerThrowResponse(updatedMatch, false, true), nil
+// activeMatch returns the match mid unless it does not exist (storage.ErrNotFound) or is finished (ErrMatchFinished).
+func (s *Service) activeMatch(ctx context.Context, mid string) (*models.Match, error) {
+	match, err := s.Store.GetActiveMatch(ctx, mid)
+	if errors.Is(err, storage.ErrNotFound) {
+		if _, err := s.Store.GetMatch(ctx, mid); err == nil {
+			return nil, ErrMatchFinished
+		}
 	}
-	// not IN not OUT not OVERTHROW => normal throw
-	// persist normal throw
-	// build persist response
-	updatedMatch, _, err := s.persistThrow(match, matchPlayerModel, &req.Throw)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("match %s: %w", mid, err)
 	}
+	return match, nil
+}
 
-	return s.Response.BuildPlayerThrowResponse(updatedMatch, false, false), nil
+// inTx runs fn with a copy of the service whose store uses a single transaction.
+func (s *Service) inTx(ctx context.Context, fn func(ctx context.Context, tx *Service) error) error {
+	return s.Store.RunInTx(ctx, func(ctx context.Context, store storage.Repository) error {
+		tx := *s
+		tx.Store = store
+		return fn(ctx, &tx)
+	})
 }
 
 func isValidThrow(throw models.ThrowType) bool {
@@ -108,111 +203,113 @@ func isValidThrow(throw models.ThrowType) bool {
 	return true
 }
 
-func isValidIn(startMode models.IO, score int, throw models.ThrowType) bool {
-	switch startMode {
-	case models.Straight:
-		return score-throw.ToPoints() > -1
-	case models.Double:
-		return throw.IsDouble() && score-throw.ToPoints() != 1
-	case models.Master:
-		return throw.IsMaster() && score-throw.ToPoints() != 1
-	}
-	return false
-}
-
-func isValidOut(endMode models.IO, _ int, throw models.ThrowType) bool {
-	switch endMode {
-	case models.Straight:
-		return true
-	case models.Double:
-		return throw.IsDouble()
-	case models.Master:
-		return throw.IsMaster()
-	}
-	return false
-}
-
-func isOverthrow(match models.Match, score int, throw models.ThrowType) bool {
-	endMode := models.MapNumberToIO(match.EndMode)
-	potentialScore := score - throw.ToPoints()
-	switch endMode {
-	case models.Straight:
-		return potentialScore < 0
-	case models.Double:
-		return potentialScore < 0 || potentialScore == 1
-	case models.Master:
-		return potentialScore < 0 || potentialScore == 1
-	}
-	return false
-}
-
-func (s *Service) persistTurnOver(match *models.Match, throw models.ThrowType) *models.Match {
-	if _, err := s.Store.CreateThrow(
-		storage.ThrowRecord{
-			Mid:       match.ID,
-			Pid:       match.CurrentPlayer,
-			EndedTurn: true,
-			ThrowType: int(throw),
-		},
-	); err != nil {
-		return nil
-	}
-	match.CurrentPlayer = match.GetNextPlayer()
-	match.CurrentThrow = 0
-	if err := s.Store.UpdateMatch(match); err != nil {
-		return nil
-	}
-
-	return match
-}
-
-func (s *Service) persistThrow(match *models.Match, matchPlayerModel *models.MatchPlayer, throw *models.ThrowType) (*models.Match, *models.MatchPlayer, error) {
-	match.Scores[match.CurrentPlayer] -= throw.ToPoints()
-	match.CurrentThrow = (match.CurrentThrow + 1) % 3
-	matchPlayerModel.OverallThrows++
-	matchPlayerModel.Score = match.Scores[match.CurrentPlayer]
-	if match.CurrentThrow == 0 && matchPlayerModel.Score > 0 {
-		match.CurrentPlayer = match.GetNextPlayer()
-	}
-
-	// determine if this throw ends the turn: either player finished (score==0) or third throw just completed
-	turnEnded := matchPlayerModel.Score == 0 || match.CurrentThrow == 0
-	_, err := s.Store.CreateThrow(
-		storage.ThrowRecord{
-			Mid:       match.ID,
-			Pid:       match.CurrentPlayer,
-			EndedTurn: turnEnded,
-			ThrowType: int(*throw),
-		},
-	)
-	if err != nil {
-		return nil, nil, err
+// applyThrow applies a throw of the current player under rules to match and thrower.
+// It remembers the score the turn started with, reverts the whole turn on a bust,
+// credits won legs and sets and advances the match to the next throw.
+func applyThrow(match *models.Match, thrower *models.MatchPlayer, rules GameRules, throw models.ThrowType) Outcome {
+	pid := thrower.Pid
+	if match.CurrentThrow == 0 {
+		thrower.TurnStartScore = match.Scores[pid]
 	}
 
-	if matchPlayerModel.Score == 0 {
-		if err := s.Store.WonMatch(match); err != nil {
-			// Non-fatal: the response will still report a win; log for debugging
-			log.Printf("warning: marking match %s as won failed: %v", match.ID, err)
-		}
+	outcome := rules.Apply(match, throw)
+	thrower.OverallThrows++
+	if outcome.Bust {
+		// a bust resets the score to what it was at the start of the turn
+		match.Scores[pid] = thrower.TurnStartScore
+		match.CurrentThrow = 0
+	} else {
+		match.CurrentThrow = (match.CurrentThrow + 1) % 3
+	}
+
+	if outcome.Won {
+		finishLeg(match, pid, &outcome)
+	} else {
+		match.CurrentPlayer = rules.NextPlayer(match, outcome)
+	}
+	syncMatchPlayer(match, thrower)
+
+	return outcome
+}
+
+// finishLeg credits pid with the leg just won and either finishes the match or starts the next leg.
+func finishLeg(match *models.Match, pid string, outcome *Outcome) {
+	match.LegsWon[pid]++
+	if match.LegsWon[pid] < match.Legs {
+		match.StartLeg()
+		return
 	}
 
-	err = s.Store.UpdateMatch(match)
+	outcome.SetWon = true
+	match.SetsWon[pid]++
+	if match.SetsWon[pid] >= match.Sets {
+		outcome.MatchWon = true
+		match.WonBy = pid
+		return
+	}
+
+	for _, p := range match.Players {
+		match.LegsWon[p] = 0
+	}
+	match.StartLeg()
+}
+
+// syncMatchPlayer copies the state of mp's player in match onto mp.
+func syncMatchPlayer(match *models.Match, mp *models.MatchPlayer) {
+	mp.Score = match.Scores[mp.Pid]
+	mp.Marks = match.Marks[mp.Pid]
+	mp.LegsWon = match.LegsWon[mp.Pid]
+	mp.SetsWon = match.SetsWon[mp.Pid]
+}
+
+// persistThrow records a throw of thrower that was already applied to match
+// and stores the updated match players and match. It returns the recorded throw.
+func (s *Service) persistThrow(ctx context.Context, match *models.Match, thrower *models.MatchPlayer, outcome Outcome, record storage.ThrowRecord) (*storage.ThrowRecord, error) {
+	pid := thrower.Pid
+
+	// a throw ends the turn if it busted, finished the leg or was the third of the turn
+	record.EndedTurn = outcome.Bust || outcome.Won || match.CurrentThrow == 0
+	created, err := s.Store.CreateThrow(ctx, record)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if outcome.Bust {
+		if err := s.Store.BustTurn(ctx, match.ID, pid, created.Turn); err != nil {
+			return nil, err
+		}
 	}
 
-	matchPlayerModel, err = s.Store.UpdateMatchPlayer(matchPlayerModel)
+	matchPlayers, err := s.Store.GetAllMatchPlayers(ctx, match.ID)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	for _, mp := range matchPlayers {
+		if mp.Pid == pid {
+			mp = thrower
+		}
+		syncMatchPlayer(match, mp)
+		if _, err := s.Store.UpdateMatchPlayer(ctx, mp); err != nil {
+			return nil, err
+		}
+	}
+
+	if outcome.MatchWon {
+		if err := s.Store.WonMatch(ctx, match); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.Store.UpdateMatch(ctx, match); err != nil {
+		return nil, err
 	}
 
-	return match, matchPlayerModel, nil
+	return created, nil
 }
 
 // GetHistory returns per-player throw lists.
 // If active is true, it returns the last relevant throws since the last turnOver (max 3) for each player.
 // If active is false (match finished), it returns all historical throws for each player.
-func (s *Service) GetHistory(match *models.Match) (*models.History, error) {
+func (s *Service) GetHistory(ctx context.Context, match *models.Match) (*models.History, error) {
 	if match == nil {
 		return nil, errors.New("match is nil")
 	}
@@ -220,9 +317,9 @@ func (s *Service) GetHistory(match *models.Match) (*models.History, error) {
 	var history *models.History
 	err := error(nil)
 	if active {
-		history, err = s.Store.GetLastTurnHistory(match)
+		history, err = s.Store.GetLastTurnHistory(ctx, match)
 	} else {
-		history, err = s.Store.GetHistory(match)
+		history, err = s.Store.GetHistory(ctx, match)
 	}
 	if err != nil {
 		return nil, err
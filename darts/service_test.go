@@ -0,0 +1,741 @@
+package darts
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	creatematch "darts-counter/cmd/server/http/createMatch"
+	leaderboard "darts-counter/cmd/server/http/leaderboard"
+	matchevents "darts-counter/cmd/server/http/matchEvents"
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	"darts-counter/models"
+	"darts-counter/response"
+	"darts-counter/storage"
+)
+
+// helper to create a service backed by a fresh in-memory store and a match between two new players
+func newServiceWithMatch(t *testing.T, req *creatematch.Request) (*Service, *models.Match) {
+	t.Helper()
+	return newServiceWithMatchIn(t, storage.NewMemoryStore(), req)
+}
+
+// helper to create a service backed by store and a match between two new players
+func newServiceWithMatchIn(t *testing.T, store storage.Repository, req *creatematch.Request) (*Service, *models.Match) {
+	t.Helper()
+	service := NewService(store, response.NewBuilder())
+
+	for _, name := range []string{"p1", "p2"} {
+		p, err := store.CreatePlayer(t.Context(), name)
+		if err != nil {
+			t.Fatalf("create player: %v", err)
+		}
+		req.Pids = append(req.Pids, p.ID)
+	}
+
+	match, err := service.CreateMatch(t.Context(), req)
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	return service, match
+}
+
+func throwAll(t *testing.T, service *Service, match *models.Match, pid string, throws ...models.ThrowType) *playerthrow.Response {
+	t.Helper()
+	var resp *playerthrow.Response
+	for _, throw := range throws {
+		var err error
+		resp, err = service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: pid, Throw: throw})
+		if err != nil {
+			t.Fatalf("throw %v: %v", throw, err)
+		}
+	}
+	return resp
+}
+
+func TestPlayerThrow_BustRevertsTurn(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 101, EndMode: models.MapIOToNumber(models.Double)})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	throwAll(t, service, match, p1, models.S1, models.S1, models.S1) // 98
+	throwAll(t, service, match, p2, models.S1, models.S1, models.S1)
+
+	resp := throwAll(t, service, match, p1, models.T20, models.T20) // 38 left after T20, then bust
+	if !resp.NotValid {
+		t.Fatalf("expected the second T20 to bust")
+	}
+	if resp.Scores[p1] != 98 {
+		t.Errorf("expected the score to revert to 98, got %d", resp.Scores[p1])
+	}
+	if resp.NextThrowBy != p2 {
+		t.Errorf("expected the turn to pass to p2")
+	}
+
+	history, err := service.Store.GetHistory(t.Context(), match)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	for _, h := range history.History[p1] {
+		if h.TurnNumber == 2 && !h.Bust {
+			t.Errorf("expected every dart of the busted turn to be marked, got %+v", h)
+		}
+		if h.TurnNumber == 1 && h.Bust {
+			t.Errorf("expected the first turn not to be marked busted, got %+v", h)
+		}
+	}
+}
+
+func TestPlayerThrow_BustedTurnCountsItsDarts(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 101, EndMode: models.MapIOToNumber(models.Double)})
+	p1 := match.Players[0]
+
+	throwAll(t, service, match, p1, models.S1, models.S1, models.S1) // 98
+	throwAll(t, service, match, match.Players[1], models.S1, models.S1, models.S1)
+	throwAll(t, service, match, p1, models.T20, models.T20) // bust
+
+	mp, err := service.Store.GetMatchPlayerModel(t.Context(), match.ID, p1)
+	if err != nil {
+		t.Fatalf("match player: %v", err)
+	}
+	if mp.OverallThrows != 5 {
+		t.Errorf("expected the darts of the busted turn to count, got %d throws", mp.OverallThrows)
+	}
+}
+
+func TestUndoThrow_ReopensWonMatchAndRollsBackToStart(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40, EndMode: models.MapIOToNumber(models.Double)})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	throwAll(t, service, match, p1, models.S20, models.S10, models.S5) // 5
+	throwAll(t, service, match, p2, models.S20)
+	throwAll(t, service, match, p2, models.S10)
+	resp := throwAll(t, service, match, p2, models.D5)
+	if !resp.Won {
+		t.Fatalf("expected p2 to win with D5")
+	}
+
+	undone, err := service.UndoThrow(t.Context(), match.ID, 0)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if undone.WonBy != "" || undone.CurrentPlayer != p2 || undone.CurrentThrow != 2 || undone.Scores[p2] != 10 {
+		t.Errorf("expected p2 back on the third dart with 10 left, got %+v", undone)
+	}
+	if _, err := service.Store.GetActiveMatch(t.Context(), match.ID); err != nil {
+		t.Errorf("expected the match to be active again: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if undone, err = service.UndoThrow(t.Context(), match.ID, 0); err != nil {
+			t.Fatalf("undo %d: %v", i, err)
+		}
+	}
+	if undone.CurrentPlayer != p1 || undone.CurrentThrow != 0 || undone.Scores[p1] != 40 || undone.Scores[p2] != 40 {
+		t.Errorf("expected the match to be back at the start, got %+v", undone)
+	}
+	if _, err := service.UndoThrow(t.Context(), match.ID, 0); err == nil {
+		t.Errorf("expected an error when there is nothing left to undo")
+	}
+}
+
+func TestEditThrow_RecomputesBustAndWinner(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 60, EndMode: models.MapIOToNumber(models.Double)})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	throwAll(t, service, match, p1, models.S20, models.S20, models.S10) // 10
+	throwAll(t, service, match, p2, models.S1, models.S1, models.S1)
+	throwAll(t, service, match, p1, models.S5, models.S1) // 4
+
+	throws, err := service.Store.GetThrows(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("throws: %v", err)
+	}
+
+	// the third dart was really a T10, which busts p1's first turn
+	edited, err := service.EditThrow(t.Context(), match.ID, throws[2].ID, models.T10, 0)
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if edited.Scores[p1] != 54 || edited.CurrentPlayer != p1 || edited.CurrentThrow != 2 {
+		t.Errorf("expected p1 on 54 with the third dart up, got %+v", edited)
+	}
+	history, err := service.Store.GetHistory(t.Context(), edited)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	for _, h := range history.History[p1] {
+		if (h.TurnNumber == 1) != h.Bust {
+			t.Errorf("expected only the darts of the first turn to be busted, got %+v", h)
+		}
+	}
+
+	// the S5 was really a D5, which wins the match and discards the dart after it
+	if _, err := service.EditThrow(t.Context(), match.ID, throws[2].ID, models.S10, 0); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	edited, err = service.EditThrow(t.Context(), match.ID, throws[6].ID, models.D5, 0)
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if edited.WonBy != p1 || edited.Scores[p1] != 0 {
+		t.Errorf("expected p1 to win with D5 on 10, got %+v", edited)
+	}
+	if remaining, err := service.Store.GetThrows(t.Context(), match.ID); err != nil || len(remaining) != 7 {
+		t.Errorf("expected the throw after the winning dart to be discarded, got %d (%v)", len(remaining), err)
+	}
+	if _, err := service.Store.GetActiveMatch(t.Context(), match.ID); err == nil {
+		t.Errorf("expected the match to be finished")
+	}
+}
+
+func TestPlayerThrow_LegsAndSets(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40, Legs: 2, Sets: 2})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	resp := throwAll(t, service, match, p1, models.D20)
+	if !resp.LegWon || resp.SetWon || resp.Won || resp.NextThrowBy != p2 || resp.Scores[p1] != 40 {
+		t.Fatalf("expected p1 to win the first leg and p2 to start the second, got %+v", resp)
+	}
+
+	resp = throwAll(t, service, match, p2, models.D20)
+	if resp.LegsWon[p1] != 1 || resp.LegsWon[p2] != 1 || resp.NextThrowBy != p1 {
+		t.Fatalf("expected legs at 1:1 with p1 starting the third leg, got %+v", resp)
+	}
+
+	resp = throwAll(t, service, match, p1, models.D20)
+	if !resp.SetWon || resp.Won || resp.SetsWon[p1] != 1 || resp.LegsWon[p1] != 0 || resp.NextThrowBy != p2 {
+		t.Fatalf("expected p1 to win the first set and p2 to start the next leg, got %+v", resp)
+	}
+
+	throwAll(t, service, match, p2, models.S20)
+	throwAll(t, service, match, p2, models.S10)
+	throwAll(t, service, match, p2, models.S5)
+	resp = throwAll(t, service, match, p1, models.D20)
+	if resp.Won || resp.LegsWon[p1] != 1 || resp.NextThrowBy != p1 {
+		t.Fatalf("expected p1 up a leg in the second set and starting the next one, got %+v", resp)
+	}
+	resp = throwAll(t, service, match, p1, models.D20)
+	if !resp.Won || resp.SetsWon[p1] != 2 {
+		t.Fatalf("expected p1 to win the match 2:0 in sets, got %+v", resp)
+	}
+
+	// replaying the recorded throws yields the same result
+	undone, err := service.UndoThrow(t.Context(), match.ID, 0)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if undone.WonBy != "" || undone.SetsWon[p1] != 1 || undone.LegsWon[p1] != 1 || undone.CurrentPlayer != p1 {
+		t.Errorf("expected the final leg to be open again, got %+v", undone)
+	}
+}
+
+func TestPlayerThrow_MissesCompleteTheTurn(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 501})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	resp := throwAll(t, service, match, p1, models.MISS, models.BOUNCE_OUT, models.MISS)
+	if resp.NotValid || resp.NextThrowBy != p2 || resp.Scores[p1] != 501 {
+		t.Errorf("expected three misses to end p1's turn without points, got %+v", resp)
+	}
+
+	mp, err := service.Store.GetMatchPlayerModel(t.Context(), match.ID, p1)
+	if err != nil {
+		t.Fatalf("match player: %v", err)
+	}
+	if mp.OverallThrows != 3 {
+		t.Errorf("expected misses to count as darts thrown, got %d", mp.OverallThrows)
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40, EndMode: models.MapIOToNumber(models.Double)})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	throwAll(t, service, match, p1, models.S20, models.S10, models.S5)
+	throwAll(t, service, match, p2, models.S20, models.D10)
+
+	stats, err := service.CollectStats(t.Context(), p2)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Name != "p2" || stats.Matches != 1 || stats.ActiveMatches != 0 || stats.Throws != 2 || stats.WinRate != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.MeanThrow != 20 || stats.HighestFinish != 40 {
+		t.Errorf("expected a mean of 20 and a 40 finish, got %+v", stats)
+	}
+	if stats.Dominating == nil || stats.Dominating.ID != p1 || stats.Nemesis != nil {
+		t.Errorf("expected p2 to dominate p1 without a nemesis, got %+v", stats)
+	}
+
+	stats, err = service.CollectStats(t.Context(), p1)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.WinRate != 0 || stats.Nemesis == nil || stats.Nemesis.ID != p2 || stats.HighestFinish != 0 {
+		t.Errorf("expected p2 to be p1's nemesis, got %+v", stats)
+	}
+
+	ps, err := service.Store.GetPlayerStats(t.Context(), p2)
+	if err != nil {
+		t.Fatalf("player stats: %v", err)
+	}
+	if ps.Matches != 1 || ps.Wins != 1 || ps.Throws != 2 || ps.TotalScore != 40 {
+		t.Errorf("expected player_stats to be refreshed when the match was won, got %+v", ps)
+	}
+
+	if _, err := service.UndoThrow(t.Context(), match.ID, 0); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if ps, err = service.Store.GetPlayerStats(t.Context(), p2); err != nil || ps.Matches != 0 || ps.Wins != 0 {
+		t.Errorf("expected player_stats to drop the re-opened match, got %+v (%v)", ps, err)
+	}
+}
+
+func TestHeadToHead(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40})
+	p1, p2 := match.Players[0], match.Players[1]
+	throwAll(t, service, match, p1, models.S20, models.S10, models.S5)
+	throwAll(t, service, match, p2, models.D20)
+
+	// a second, unfinished match between the two
+	second, err := service.CreateMatch(t.Context(), &creatematch.Request{Pids: []string{p1, p2}, StartAt: 40})
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	throwAll(t, service, second, p1, models.S20)
+
+	resp, err := service.HeadToHead(t.Context(), p1, p2)
+	if err != nil {
+		t.Fatalf("head to head: %v", err)
+	}
+	if resp.Played != 2 || resp.Finished != 1 || resp.Player.Wins != 0 || resp.Opponent.Wins != 1 || len(resp.Matches) != 2 {
+		t.Errorf("unexpected record %+v", resp)
+	}
+	if resp.Opponent.HighestCheckout != 40 || resp.Opponent.ThreeDartAverage != 120 || resp.Player.ThreeDartAverage != 41.25 {
+		t.Errorf("unexpected averages or checkouts %+v / %+v", resp.Player, resp.Opponent)
+	}
+
+	if _, err := service.HeadToHead(t.Context(), p1, p1); err == nil {
+		t.Errorf("expected an error for a player against themselves")
+	}
+}
+
+func TestRating_UpdatedOnWinAndRolledBackOnUndo(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	throwAll(t, service, match, p1, models.S20, models.S20)
+
+	winner, err := service.Rating(t.Context(), p1)
+	if err != nil {
+		t.Fatalf("rating: %v", err)
+	}
+	loser, err := service.Rating(t.Context(), p2)
+	if err != nil {
+		t.Fatalf("rating: %v", err)
+	}
+	if winner.Rating != 1516 || loser.Rating != 1484 {
+		t.Errorf("expected 1516/1484 after an even match, got %v/%v", winner.Rating, loser.Rating)
+	}
+	if len(winner.History) != 1 || winner.History[0].Mid != match.ID || winner.History[0].Delta != 16 {
+		t.Errorf("expected one +16 history point, got %+v", winner.History)
+	}
+
+	if _, err := service.UndoThrow(t.Context(), match.ID, 0); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if winner, err = service.Rating(t.Context(), p1); err != nil {
+		t.Fatalf("rating: %v", err)
+	}
+	if winner.Rating != 1500 || len(winner.History) != 0 {
+		t.Errorf("expected the rating to be rolled back, got %+v", winner)
+	}
+
+	if err := service.Store.RecomputeRatings(t.Context()); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+	throwAll(t, service, match, p1, models.S20)
+	if loser, err = service.Rating(t.Context(), p2); err != nil {
+		t.Fatalf("rating: %v", err)
+	}
+	if loser.Rating != 1484 || len(loser.History) != 1 {
+		t.Errorf("expected the match to be rated again, got %+v", loser)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40})
+	p1, p2 := match.Players[0], match.Players[1]
+	throwAll(t, service, match, p1, models.S20, models.S20)
+
+	resp, err := service.Leaderboard(t.Context(), &leaderboard.Request{Metric: leaderboard.Rating, Window: leaderboard.LastWeek})
+	if err != nil {
+		t.Fatalf("leaderboard: %v", err)
+	}
+	if len(resp.Entries) != 2 || resp.Entries[0].Player.ID != p1 || resp.Entries[1].Player.ID != p2 {
+		t.Fatalf("expected p1 ahead of p2, got %+v", resp.Entries)
+	}
+	if first := resp.Entries[0]; first.Rank != 1 || first.Matches != 1 || first.Wins != 1 || first.WinRate != 1 || first.ThreeDartAverage != 60 {
+		t.Errorf("unexpected entry for p1: %+v", first)
+	}
+
+	if _, err := service.Store.CreatePlayer(t.Context(), "p3"); err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	if resp, err = service.Leaderboard(t.Context(), &leaderboard.Request{MinMatches: 1}); err != nil {
+		t.Fatalf("leaderboard: %v", err)
+	}
+	if len(resp.Entries) != 2 || resp.Metric != leaderboard.WinRate || resp.Window != leaderboard.AllTime {
+		t.Errorf("expected players without matches to be filtered, got %+v", resp)
+	}
+
+	if _, err := service.Leaderboard(t.Context(), &leaderboard.Request{Window: "1y"}); err == nil {
+		t.Errorf("expected an error for an unknown window")
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40})
+	p1 := match.Players[0]
+
+	stored, err := service.Store.GetMatch(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if stored.CreatedAt.Before(before) || stored.StartedAt != nil || stored.FinishedAt != nil {
+		t.Errorf("expected only a creation time before the first throw, got %+v", stored)
+	}
+	player, err := service.Store.GetPlayer(t.Context(), p1)
+	if err != nil {
+		t.Fatalf("get player: %v", err)
+	}
+	if player.CreatedAt.Before(before) {
+		t.Errorf("expected a creation time for the player, got %v", player.CreatedAt)
+	}
+
+	throwAll(t, service, match, p1, models.S20, models.S20)
+	if stored, err = service.Store.GetMatch(t.Context(), match.ID); err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if stored.StartedAt == nil || stored.FinishedAt == nil || stored.FinishedAt.Before(*stored.StartedAt) {
+		t.Errorf("expected start and finish times, got %v and %v", stored.StartedAt, stored.FinishedAt)
+	}
+	history, err := service.GetHistory(t.Context(), stored)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	for _, h := range history.History[p1] {
+		if h.ThrownAt.Before(*stored.StartedAt) {
+			t.Errorf("expected throw %d to be thrown after the start, got %v", h.ID, h.ThrownAt)
+		}
+	}
+
+	if _, err := service.UndoThrow(t.Context(), match.ID, 0); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if stored, err = service.Store.GetMatch(t.Context(), match.ID); err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if stored.FinishedAt != nil {
+		t.Errorf("expected the finish time to be cleared on reopen, got %v", stored.FinishedAt)
+	}
+}
+
+func TestPlayerThrow_FailedThrowLeavesNoTrace(t *testing.T) {
+	store := storage.NewStorage(filepath.Join(t.TempDir(), "darts.db"))
+	service, match := newServiceWithMatchIn(t, store, &creatematch.Request{StartAt: 40})
+	p1 := match.Players[0]
+	throwAll(t, service, match, p1, models.S20)
+
+	// storing the rating of the win fails after the throw, match and players were already written
+	if _, err := store.Bun.ExecContext(t.Context(), `DROP TABLE "rating_history"`); err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+	if _, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p1, Throw: models.S20}); err == nil {
+		t.Fatalf("expected the winning throw to fail")
+	}
+
+	stored, err := service.Store.GetActiveMatch(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("expected the match to still be active: %v", err)
+	}
+	if stored.Scores[p1] != 20 || stored.CurrentThrow != 1 || stored.WonBy != "" {
+		t.Errorf("expected the state before the failed throw, got %+v", stored)
+	}
+	throws, err := service.Store.GetThrows(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("get throws: %v", err)
+	}
+	if len(throws) != 1 {
+		t.Errorf("expected only the first throw to be recorded, got %d", len(throws))
+	}
+	mp, err := service.Store.GetMatchPlayerModel(t.Context(), match.ID, p1)
+	if err != nil {
+		t.Fatalf("get match player: %v", err)
+	}
+	if mp.Score != 20 || mp.LegsWon != 0 {
+		t.Errorf("expected the match player before the failed throw, got %+v", mp)
+	}
+}
+
+func TestPlayerThrow_RejectsStaleVersion(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 301})
+	p1 := match.Players[0]
+
+	resp, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p1, Throw: models.S20, Version: match.Version})
+	if err != nil {
+		t.Fatalf("throw: %v", err)
+	}
+	if resp.Version != match.Version+1 {
+		t.Errorf("expected the version to be bumped to %d, got %d", match.Version+1, resp.Version)
+	}
+
+	// a second tablet still on the version before the throw
+	_, err = service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p1, Throw: models.S20, Version: match.Version})
+	var stale *StaleVersionError
+	if !errors.As(err, &stale) || !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected a stale version error, got %v", err)
+	}
+	if stale.Match.Version != resp.Version || stale.Match.Scores[p1] != 281 {
+		t.Errorf("expected the current state with the error, got %+v", stale.Match)
+	}
+
+	if _, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p1, Throw: models.S20, Version: resp.Version}); err != nil {
+		t.Errorf("throw on the current version: %v", err)
+	}
+}
+
+func TestPlayerThrow_ConcurrentThrowsAreSerialized(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 501})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	const throws = 12
+	var wg sync.WaitGroup
+	errs := make(chan error, throws)
+	for range throws {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Throw: models.S1})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("throw: %v", err)
+		}
+	}
+
+	stored, err := service.Store.GetMatch(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if stored.Scores[p1]+stored.Scores[p2] != 2*501-throws || stored.Version != match.Version+throws {
+		t.Errorf("expected every throw to be applied exactly once, got scores %v version %d", stored.Scores, stored.Version)
+	}
+}
+
+func TestPlayerThrow_TurnOrder(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 301})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	_, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p2, Throw: models.S20})
+	if !errors.Is(err, ErrNotYourTurn) {
+		t.Fatalf("expected p2 to be rejected while p1 is up, got %v", err)
+	}
+	stored, err := service.Store.GetMatch(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if stored.Scores[p1] != 301 || stored.Scores[p2] != 301 || stored.CurrentThrow != 0 {
+		t.Errorf("expected the rejected throw to change nothing, got %+v", stored)
+	}
+
+	// scorer mode: the throws go to whoever is up
+	resp := throwAll(t, service, match, "", models.T20, models.T20, models.T20)
+	if resp.Scores[p1] != 121 || resp.NextThrowBy != p2 {
+		t.Errorf("expected p1 to score 180 and p2 to be up, got %+v", resp)
+	}
+	resp = throwAll(t, service, match, p2, models.S20)
+	if resp.Scores[p2] != 281 {
+		t.Errorf("expected p2's own throw to count, got %+v", resp.Scores)
+	}
+}
+
+// drain returns the types of the events waiting on ch.
+func drain(ch <-chan matchevents.Event) []matchevents.Type {
+	var types []matchevents.Type
+	for {
+		select {
+		case ev := <-ch:
+			types = append(types, ev.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestEvents_PublishedForEveryChange(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40, EndMode: models.MapIOToNumber(models.Double)})
+	p1, p2 := match.Players[0], match.Players[1]
+	events, unsubscribe := service.Events.Subscribe(match.ID)
+	defer unsubscribe()
+
+	throwAll(t, service, match, p1, models.S20, models.S10, models.S5) // 5
+	if got := drain(events); !slices.Equal(got, []matchevents.Type{"throw", "throw", "throw", "turn"}) {
+		t.Errorf("expected three throws and a turn change, got %v", got)
+	}
+	throwAll(t, service, match, p2, models.T20) // bust
+	if got := drain(events); !slices.Equal(got, []matchevents.Type{"throw", "bust", "turn"}) {
+		t.Errorf("expected a bust and a turn change, got %v", got)
+	}
+
+	if _, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p2, Throw: models.S1}); err == nil {
+		t.Fatalf("expected p2 to be rejected while p1 is up")
+	}
+	if got := drain(events); len(got) != 0 {
+		t.Errorf("expected a rejected throw to publish nothing, got %v", got)
+	}
+
+	throwAll(t, service, match, p1, models.S1, models.D2)
+	if got := drain(events); !slices.Equal(got, []matchevents.Type{"throw", "throw", "leg", "won"}) {
+		t.Errorf("expected the winning throw to end the leg and the match, got %v", got)
+	}
+
+	if _, err := service.UndoThrow(t.Context(), match.ID, 0); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	throws, err := service.Store.GetThrows(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("throws: %v", err)
+	}
+	if _, err := service.EditThrow(t.Context(), match.ID, throws[0].ID, models.S19, 0); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if got := drain(events); !slices.Equal(got, []matchevents.Type{"undo", "edit"}) {
+		t.Errorf("expected an undo and an edit, got %v", got)
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Errorf("expected the channel to be closed after unsubscribing")
+	}
+}
+
+func TestSnapshot_ReplaysThrowsAfterID(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 301})
+	p1 := match.Players[0]
+	other, err := service.CreateMatch(t.Context(), &creatematch.Request{Pids: match.Players, StartAt: 301})
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	all, unsubscribe := service.Events.Subscribe("")
+	defer unsubscribe()
+
+	throwAll(t, service, match, p1, models.S20)
+	throwAll(t, service, other, p1, models.S19)
+	throwAll(t, service, match, p1, models.S18)
+	if got := drain(all); len(got) != 3 {
+		t.Errorf("expected the events of both matches, got %v", got)
+	}
+	throws, err := service.Store.GetThrows(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("throws: %v", err)
+	}
+
+	snapshot, err := service.Snapshot(t.Context(), match.ID, throws[0].ID)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(snapshot) != 2 || snapshot[0].ThrowID != throws[1].ID || snapshot[0].Throw != models.S18 ||
+		snapshot[1].Type != matchevents.State || snapshot[1].Match.Scores[p1] != 263 {
+		t.Errorf("expected the missed S18 followed by the state, got %+v", snapshot)
+	}
+
+	snapshot, err = service.Snapshot(t.Context(), "", throws[0].ID)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	var types []matchevents.Type
+	for _, ev := range snapshot {
+		types = append(types, ev.Type)
+	}
+	if !slices.Equal(types, []matchevents.Type{"throw", "throw", "state", "state"}) || snapshot[0].Throw != models.S19 {
+		t.Errorf("expected the missed throws of every active match in order before their states, got %+v", snapshot)
+	}
+
+	if _, err := service.Snapshot(t.Context(), p1, 0); err == nil {
+		t.Errorf("expected an unknown match to fail")
+	}
+}
+
+func TestSnapshot_CatchesUpOnMatchFinishedWhileAway(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40, EndMode: models.MapIOToNumber(models.Double)})
+	p1 := match.Players[0]
+	other, err := service.CreateMatch(t.Context(), &creatematch.Request{Pids: match.Players, StartAt: 301})
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	throwAll(t, service, other, p1, models.S20)
+	seen, err := service.Store.GetThrows(t.Context(), other.ID)
+	if err != nil {
+		t.Fatalf("throws: %v", err)
+	}
+	lastSeen := seen[0].ID
+
+	// the client is away while the match is won
+	throwAll(t, service, match, p1, models.D20)
+	snapshot, err := service.Snapshot(t.Context(), "", lastSeen)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(snapshot) != 3 || snapshot[0].Type != matchevents.Throw || snapshot[0].Throw != models.D20 ||
+		snapshot[1].Type != matchevents.State || snapshot[1].Mid != match.ID || snapshot[1].Match.WonBy != p1 ||
+		snapshot[2].Mid != other.ID {
+		t.Errorf("expected the winning throw and the final state before the active match, got %+v", snapshot)
+	}
+
+	won, err := service.Store.GetThrows(t.Context(), match.ID)
+	if err != nil {
+		t.Fatalf("throws: %v", err)
+	}
+	for _, after := range []int64{0, won[0].ID} {
+		snapshot, err := service.Snapshot(t.Context(), "", after)
+		if err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+		if len(snapshot) != 1 || snapshot[0].Mid != other.ID {
+			t.Errorf("after %d: expected only the active match once caught up, got %+v", after, snapshot)
+		}
+	}
+}
+
+func TestPlayerThrow_SentinelErrors(t *testing.T) {
+	service, match := newServiceWithMatch(t, &creatematch.Request{StartAt: 40, EndMode: models.MapIOToNumber(models.Double)})
+	p1, p2 := match.Players[0], match.Players[1]
+
+	if _, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Throw: 0}); !errors.Is(err, ErrInvalidThrow) {
+		t.Errorf("expected ErrInvalidThrow, got %v", err)
+	}
+	if _, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: p1, Throw: models.S1}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound for a missing match, got %v", err)
+	}
+	if _, err := service.UndoThrow(t.Context(), match.ID, 0); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest without a throw to undo, got %v", err)
+	}
+	if _, err := service.EditThrow(t.Context(), match.ID, 42, models.S1, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound for a missing throw, got %v", err)
+	}
+
+	throwAll(t, service, match, p1, models.D20)
+	if _, err := service.PlayerThrow(t.Context(), &playerthrow.Request{Mid: match.ID, Pid: p2, Throw: models.S1}); !errors.Is(err, ErrMatchFinished) {
+		t.Errorf("expected ErrMatchFinished, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package darts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	playerstats "darts-counter/cmd/server/http/playerStats"
+	models "darts-counter/models"
+	storage "darts-counter/storage"
+)
+
+// leg is a leg of a match with the throws of every player in it in throwing order.
+type leg struct {
+	number int
+	// winner is empty while the leg is still being played
+	winner string
+	throws map[string][]*storage.ThrowRecord
+}
+
+// splitLegs splits the throws of a match into its legs.
+// The last throw of a won leg is always the winning throw, so the leg goes to whoever threw it.
+func splitLegs(match *models.Match, throws []*storage.ThrowRecord) []leg {
+	legs := make([]leg, 0, match.Leg+1)
+	for _, tr := range throws {
+		if len(legs) == 0 || legs[len(legs)-1].number != tr.Leg {
+			legs = append(legs, leg{number: tr.Leg, throws: map[string][]*storage.ThrowRecord{}})
+		}
+		l := &legs[len(legs)-1]
+		l.throws[tr.Pid] = append(l.throws[tr.Pid], tr)
+		l.winner = tr.Pid
+	}
+
+	// the current leg is only finished once the match is won
+	if len(legs) > 0 && legs[len(legs)-1].number == match.Leg && match.WonBy == "" {
+		legs[len(legs)-1].winner = ""
+	}
+	return legs
+}
+
+// checkout returns the points of the turn that won the leg.
+func (l leg) checkout() int {
+	throws := l.throws[l.winner]
+	last := throws[len(throws)-1]
+	points := 0
+	for _, tr := range throws {
+		if tr.Turn == last.Turn && !tr.Busted {
+			points += models.ThrowType(tr.ThrowType).ToPoints()
+		}
+	}
+	return points
+}
+
+// x01Tally accumulates the raw counts behind models.X01Stats over any number of legs.
+type x01Tally struct {
+	points, darts                   int
+	firstNinePoints, firstNineDarts int
+	checkoutAttempts, checkouts     int
+	tons, tonForties, oneEighties   int
+	bestLeg                         int
+	highestFinish                   int
+}
+
+// addLeg tallies the turns pid threw in an X01 leg.
+func (t *x01Tally) addLeg(match *models.Match, l leg, pid string) {
+	throws := l.throws[pid]
+	if len(throws) == 0 {
+		return
+	}
+
+	finishes := map[int]bool{}
+	for _, throw := range models.MapNumberToIO(match.EndMode).GetAllFinishingThrows() {
+		finishes[throw.ToPoints()] = true
+	}
+
+	score := match.StartAt
+	turns := 0
+	for start := 0; start < len(throws); {
+		end := start
+		for end < len(throws) && throws[end].Turn == throws[start].Turn {
+			end++
+		}
+		turn := throws[start:end]
+		turns++
+
+		remaining := score
+		for _, tr := range turn {
+			if finishes[remaining] {
+				t.checkoutAttempts++
+			}
+			remaining -= models.ThrowType(tr.ThrowType).ToPoints()
+		}
+		points := 0
+		if !turn[0].Busted {
+			points = score - remaining
+			score = remaining
+		}
+
+		t.darts += len(turn)
+		t.points += points
+		if turns <= 3 {
+			t.firstNineDarts += len(turn)
+			t.firstNinePoints += points
+		}
+		switch {
+		case points == 180:
+			t.oneEighties++
+		case points >= 140:
+			t.tonForties++
+		case points >= 100:
+			t.tons++
+		}
+		start = end
+	}
+
+	if l.winner == pid {
+		t.checkouts++
+		t.highestFinish = max(t.highestFinish, l.checkout())
+		if t.bestLeg == 0 || len(throws) < t.bestLeg {
+			t.bestLeg = len(throws)
+		}
+	}
+}
+
+// addMatch tallies every leg pid threw in an X01 match.
+func (t *x01Tally) addMatch(match *models.Match, throws []*storage.ThrowRecord, pid string) {
+	for _, l := range splitLegs(match, throws) {
+		t.addLeg(match, l, pid)
+	}
+}
+
+func (t *x01Tally) stats() models.X01Stats {
+	stats := models.X01Stats{
+		CheckoutAttempts: t.checkoutAttempts,
+		Checkouts:        t.checkouts,
+		Tons:             t.tons,
+		TonForties:       t.tonForties,
+		OneEighties:      t.oneEighties,
+		BestLeg:          t.bestLeg,
+	}
+	if t.darts > 0 {
+		stats.ThreeDartAverage = float32(t.points) * 3 / float32(t.darts)
+	}
+	if t.firstNineDarts > 0 {
+		stats.FirstNineAverage = float32(t.firstNinePoints) * 3 / float32(t.firstNineDarts)
+	}
+	if t.checkoutAttempts > 0 {
+		stats.CheckoutPercentage = float32(t.checkouts) * 100 / float32(t.checkoutAttempts)
+	}
+	return stats
+}
+
+// MatchStats returns the X01 metrics of every player in a match.
+func (s *Service) MatchStats(ctx context.Context, mid string) (map[string]models.X01Stats, error) {
+	match, err := s.Store.GetMatch(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+	if match.GameType != models.X01 {
+		return nil, fmt.Errorf("%w: statistics are only available for X01 matches", ErrInvalidRequest)
+	}
+	throws, err := s.Store.GetThrows(ctx, mid)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]models.X01Stats, len(match.Players))
+	for _, pid := range match.Players {
+		var tally x01Tally
+		tally.addMatch(match, throws, pid)
+		out[pid] = tally.stats()
+	}
+	return out, nil
+}
+
+// CollectStats aggregates statistics for the given player ID.
+func (s *Service) CollectStats(ctx context.Context, pid string) (*playerstats.Response, error) {
+	player, err := s.Store.GetPlayer(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.Store.GetPlayerMatches(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &playerstats.Response{Name: player.Name}
+	beatenBy := map[string]int{}
+	beaten := map[string]int{}
+	finished, won := 0, 0
+	var tally x01Tally
+
+	for _, match := range matches {
+		resp.Matches++
+		switch match.WonBy {
+		case "":
+			resp.ActiveMatches++
+		case pid:
+			finished++
+			won++
+			for _, opponent := range match.Players {
+				if opponent != pid {
+					beaten[opponent]++
+				}
+			}
+		default:
+			finished++
+			beatenBy[match.WonBy]++
+		}
+
+		throws, err := s.Store.GetThrows(ctx, match.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, tr := range throws {
+			if tr.Pid == pid {
+				resp.Throws++
+			}
+		}
+		if match.GameType == models.X01 {
+			tally.addMatch(match, throws, pid)
+		}
+	}
+
+	if finished > 0 {
+		resp.WinRate = float32(won) / float32(finished)
+	}
+	if tally.darts > 0 {
+		resp.MeanThrow = float32(tally.points) / float32(tally.darts)
+	}
+	resp.HighestFinish = uint32(tally.highestFinish)
+	resp.X01 = tally.stats()
+	if resp.Nemesis, err = s.mostFrequent(ctx, beatenBy); err != nil {
+		return nil, err
+	}
+	if resp.Dominating, err = s.mostFrequent(ctx, beaten); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// mostFrequent returns the player with the highest count, ties going to the lower player ID.
+func (s *Service) mostFrequent(ctx context.Context, counts map[string]int) (*models.Player, error) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	pids := make([]string, 0, len(counts))
+	for pid := range counts {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		if counts[pids[i]] == counts[pids[j]] {
+			return pids[i] < pids[j]
+		}
+		return counts[pids[i]] > counts[pids[j]]
+	})
+
+	return s.Store.GetPlayer(ctx, pids[0])
+}
@@ -0,0 +1,68 @@
+package darts
+
+import (
+	"testing"
+
+	"darts-counter/models"
+	"darts-counter/storage"
+)
+
+// helper to record the turns of a single player leg, busted turns are flagged
+func legThrows(pid string, turns ...[]models.ThrowType) []*storage.ThrowRecord {
+	var throws []*storage.ThrowRecord
+	for i, turn := range turns {
+		for _, throw := range turn {
+			throws = append(throws, &storage.ThrowRecord{Pid: pid, ThrowType: int(throw), Turn: i + 1})
+		}
+	}
+	return throws
+}
+
+func TestX01Tally_Metrics(t *testing.T) {
+	match := &models.Match{
+		Players: []string{"p1"},
+		StartAt: 301,
+		EndMode: models.MapIOToNumber(models.Double),
+		WonBy:   "p1",
+	}
+	throws := legThrows("p1",
+		[]models.ThrowType{models.T20, models.T20, models.T20}, // 180, 121 left
+		[]models.ThrowType{models.T20, models.T20},             // leaves 1, bust
+		[]models.ThrowType{models.T20, models.T19, models.D2},  // 121 checkout
+	)
+	for _, tr := range throws[3:5] {
+		tr.Busted = true
+	}
+
+	var tally x01Tally
+	tally.addMatch(match, throws, "p1")
+	stats := tally.stats()
+
+	if stats.ThreeDartAverage != float32(301)*3/8 || stats.FirstNineAverage != stats.ThreeDartAverage {
+		t.Errorf("expected averages over 8 darts for 301 points, got %+v", stats)
+	}
+	if stats.CheckoutAttempts != 1 || stats.Checkouts != 1 || stats.CheckoutPercentage != 100 {
+		t.Errorf("expected one dart at a double that was hit, got %+v", stats)
+	}
+	if stats.OneEighties != 1 || stats.Tons != 1 || stats.TonForties != 0 {
+		t.Errorf("expected one 180 and one ton, got %+v", stats)
+	}
+	if stats.BestLeg != 8 || tally.highestFinish != 121 {
+		t.Errorf("expected an 8 dart leg with a 121 finish, got %+v / %d", stats, tally.highestFinish)
+	}
+}
+
+func TestSplitLegs_CurrentLegHasNoWinner(t *testing.T) {
+	match := &models.Match{Players: []string{"p1", "p2"}, Leg: 1}
+	throws := []*storage.ThrowRecord{
+		{Pid: "p1", Leg: 0},
+		{Pid: "p2", Leg: 0},
+		{Pid: "p2", Leg: 1},
+	}
+
+	legs := splitLegs(match, throws)
+
+	if len(legs) != 2 || legs[0].winner != "p2" || legs[1].winner != "" {
+		t.Errorf("expected the first leg won by p2 and the second open, got %+v", legs)
+	}
+}
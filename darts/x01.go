@@ -0,0 +1,100 @@
+package darts
+
+import (
+	playerthrow "darts-counter/cmd/server/http/playerThrow"
+	models "darts-counter/models"
+	response "darts-counter/response"
+)
+
+// X01 counts every player down from StartAt to exactly zero honoring the match In/Out modes.
+type X01 struct{}
+
+// StartScore returns startAt, the score every player counts down from.
+func (X01) StartScore(startAt int) int {
+	return startAt
+}
+
+// Apply deducts the throw from the current player's score unless it is not a valid in,
+// not a valid out or an overthrow.
+func (X01) Apply(match *models.Match, throw models.ThrowType) Outcome {
+	pid := match.CurrentPlayer
+	score := match.Scores[pid]
+
+	if score == match.StartAt && !isValidIn(models.MapNumberToIO(match.StartMode), score, throw) { // is IN
+		return Outcome{Bust: true}
+	}
+
+	if score-throw.ToPoints() == 0 { // is OUT
+		if !isValidOut(models.MapNumberToIO(match.EndMode), score, throw) {
+			return Outcome{Bust: true}
+		}
+		match.Scores[pid] = 0
+		return Outcome{Won: true}
+	}
+
+	if isOverthrow(*match, score, throw) {
+		return Outcome{Bust: true}
+	}
+
+	// not IN not OUT not OVERTHROW => normal throw
+	match.Scores[pid] -= throw.ToPoints()
+	return Outcome{}
+}
+
+// NextPlayer passes the turn on after three throws or a bust.
+func (X01) NextPlayer(match *models.Match, outcome Outcome) string {
+	return nextInRotation(match, outcome)
+}
+
+// Describe suggests a checkout for the player throwing next.
+func (X01) Describe(match *models.Match, resp *playerthrow.Response) {
+	resp.PossibleFinish = response.PossibleFinish(match)
+}
+
+func isValidIn(startMode models.IO, score int, throw models.ThrowType) bool {
+	if throw.IsMiss() {
+		// a miss does not score, the player stays out and keeps throwing
+		return true
+	}
+	switch startMode {
+	case models.Straight:
+		return score-throw.ToPoints() > -1
+	case models.Double:
+		return throw.IsDouble() && score-throw.ToPoints() != 1
+	case models.Master:
+		return throw.IsMaster() && score-throw.ToPoints() != 1
+	}
+	return false
+}
+
+func isValidOut(endMode models.IO, _ int, throw models.ThrowType) bool {
+	if throw.IsMiss() {
+		return false
+	}
+	switch endMode {
+	case models.Straight:
+		return true
+	case models.Double:
+		return throw.IsDouble()
+	case models.Master:
+		return throw.IsMaster()
+	}
+	return false
+}
+
+func isOverthrow(match models.Match, score int, throw models.ThrowType) bool {
+	if throw.IsMiss() {
+		return false
+	}
+	endMode := models.MapNumberToIO(match.EndMode)
+	potentialScore := score - throw.ToPoints()
+	switch endMode {
+	case models.Straight:
+		return potentialScore < 0
+	case models.Double:
+		return potentialScore < 0 || potentialScore == 1
+	case models.Master:
+		return potentialScore < 0 || potentialScore == 1
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package darts
+
+import (
+	"testing"
+
+	"darts-counter/models"
+)
+
+// helper to create an X01 match where p1 has the given score
+func newX01Match(startAt, score int, startMode, endMode models.IO) *models.Match {
+	return &models.Match{
+		ID:            "test-match",
+		Players:       []string{"p1", "p2"},
+		CurrentPlayer: "p1",
+		StartAt:       startAt,
+		StartMode:     models.MapIOToNumber(startMode),
+		EndMode:       models.MapIOToNumber(endMode),
+		Scores:        map[string]int{"p1": score, "p2": startAt},
+	}
+}
+
+func TestX01Apply_NormalThrowDeducts(t *testing.T) {
+	match := newX01Match(501, 501, models.Straight, models.Double)
+
+	outcome := X01{}.Apply(match, models.T20)
+
+	if outcome.Bust || outcome.Won || match.Scores["p1"] != 441 {
+		t.Errorf("expected 441 without bust or win, got %d %+v", match.Scores["p1"], outcome)
+	}
+}
+
+func TestX01Apply_DoubleInRequiresDouble(t *testing.T) {
+	match := newX01Match(501, 501, models.Double, models.Double)
+
+	outcome := X01{}.Apply(match, models.T20)
+
+	if !outcome.Bust || match.Scores["p1"] != 501 {
+		t.Errorf("expected a bust without deduction, got %d %+v", match.Scores["p1"], outcome)
+	}
+}
+
+func TestX01Apply_OverthrowBusts(t *testing.T) {
+	match := newX01Match(501, 41, models.Straight, models.Double)
+
+	outcome := X01{}.Apply(match, models.D20)
+
+	if !outcome.Bust || match.Scores["p1"] != 41 {
+		t.Errorf("expected leaving 1 to bust on double out, got %d %+v", match.Scores["p1"], outcome)
+	}
+}
+
+func TestX01Apply_DoubleOutWins(t *testing.T) {
+	match := newX01Match(501, 40, models.Straight, models.Double)
+
+	if outcome := (X01{}).Apply(match, models.S20); outcome.Won {
+		t.Errorf("expected no win with a single 20 on 20 left")
+	}
+	if outcome := (X01{}).Apply(match, models.D10); !outcome.Won || match.Scores["p1"] != 0 {
+		t.Errorf("expected D10 to win on 20 left, got %d %+v", match.Scores["p1"], outcome)
+	}
+}
+
+func TestRulesFor_UnknownGameType(t *testing.T) {
+	if _, err := RulesFor(models.GameType(200)); err == nil {
+		t.Errorf("expected an error for an unregistered game type")
+	}
+}
+
+func TestX01Apply_MissNeverBusts(t *testing.T) {
+	match := newX01Match(501, 501, models.Double, models.Double)
+
+	if outcome := (X01{}).Apply(match, models.MISS); outcome.Bust || match.Scores["p1"] != 501 {
+		t.Errorf("expected a miss before double in to keep the turn going, got %d %+v", match.Scores["p1"], outcome)
+	}
+
+	match.Scores["p1"] = 2
+	if outcome := (X01{}).Apply(match, models.BOUNCE_OUT); outcome.Bust || outcome.Won || match.Scores["p1"] != 2 {
+		t.Errorf("expected a bounce out on a finish to score nothing, got %d %+v", match.Scores["p1"], outcome)
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+// CricketClosed is the number of marks needed to close a cricket number.
+const CricketClosed = 3
+
+// CricketBull is the segment number used for the bull in cricket marks.
+const CricketBull = 25
+
+// CricketNumbers are the segments in play for cricket.
+var CricketNumbers = []int{15, 16, 17, 18, 19, 20, CricketBull}
+
+// CricketMarks maps a cricket number to the marks a player has on it (0-3).
+type CricketMarks map[int]int
+
+// IsCricketNumber reports whether the segment is in play for cricket.
+func IsCricketNumber(segment int) bool {
+	for _, n := range CricketNumbers {
+		if n == segment {
+			return true
+		}
+	}
+	return false
+}
+
+// AllClosed reports whether every cricket number has been closed.
+func (cm CricketMarks) AllClosed() bool {
+	for _, n := range CricketNumbers {
+		if cm[n] < CricketClosed {
+			return false
+		}
+	}
+	return true
+}
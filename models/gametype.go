@@ -0,0 +1,18 @@
+package models
+
+// GameType selects the rule set a match is played with.
+type GameType uint8
+
+const (
+	// X01 counts down from StartAt to zero honoring the In/Out modes.
+	X01 GameType = iota
+	// Cricket is standard cricket: close 15-20 and bull, points go to the thrower.
+	Cricket
+	// CutThroatCricket is cricket where points go to opponents and the lowest score wins.
+	CutThroatCricket
+)
+
+// IsCricket reports whether the GameType is one of the cricket variants.
+func (gt GameType) IsCricket() bool {
+	return gt == Cricket || gt == CutThroatCricket
+}
@@ -1,9 +1,15 @@
 package models
 
+import "time"
+
 type HistoryElement struct {
+	ID         int64     `json:"id"`
 	Throw      ThrowType `json:"throw"`
 	EndedTurn  bool      `json:"ended_turn"`
 	TurnNumber int       `json:"turn_number"`
+	Bust       bool      `json:"bust"`
+	Leg        int       `json:"leg"`
+	ThrownAt   time.Time `json:"thrown_at"`
 }
 
 type History struct {
@@ -1,16 +1,49 @@
 package models
 
+import "time"
+
 // Match represents a darts match state.
 type Match struct {
-	ID            string         `json:"id"`
-	Players       []string       `json:"players"`
-	CurrentThrow  uint32         `json:"currentThrow"`
-	CurrentPlayer string         `json:"currentPlayer"`
-	WonBy         string         `json:"wonBy"`
-	StartAt       int            `json:"startAt"`
-	StartMode     uint8          `json:"startMode"`
-	EndMode       uint8          `json:"endMode"`
-	Scores        map[string]int `json:"scores"`
+	ID            string                  `json:"id"`
+	Players       []string                `json:"players"`
+	GameType      GameType                `json:"gameType"`
+	CurrentThrow  uint32                  `json:"currentThrow"`
+	CurrentPlayer string                  `json:"currentPlayer"`
+	WonBy         string                  `json:"wonBy"`
+	StartAt       int                     `json:"startAt"`
+	StartMode     uint8                   `json:"startMode"`
+	EndMode       uint8                   `json:"endMode"`
+	Scores        map[string]int          `json:"scores"`
+	Marks         map[string]CricketMarks `json:"marks,omitempty"`
+	// Legs is the number of legs needed to win a set, Sets the number of sets needed to win the match.
+	Legs int `json:"legs"`
+	Sets int `json:"sets"`
+	// Leg counts the legs finished in the match so far, it decides who starts the current leg.
+	Leg     int            `json:"leg"`
+	LegsWon map[string]int `json:"legsWon"`
+	SetsWon map[string]int `json:"setsWon"`
+	// Version counts the changes to the match. Clients send it with a throw so that throws scored
+	// on an outdated state are rejected.
+	Version int `json:"version"`
+	// StartedAt is nil until the first throw, FinishedAt until the match is won.
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// StartLeg resets the scores for a new leg and lets the next player in the rotation start it.
+func (m *Match) StartLeg() {
+	m.Leg++
+	m.CurrentThrow = 0
+	for _, pid := range m.Players {
+		m.Scores[pid] = m.StartAt
+		if m.Marks != nil {
+			m.Marks[pid] = CricketMarks{}
+		}
+	}
+	if len(m.Players) > 0 {
+		m.CurrentPlayer = m.Players[m.Leg%len(m.Players)]
+	}
 }
 
 // GetNextPlayer returns the next player's ID in the rotation.
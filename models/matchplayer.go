@@ -4,6 +4,13 @@ package models
 type MatchPlayer struct {
 	Mid           string
 	Pid           string
+	Seat          int
 	OverallThrows int
 	Score         int
+	// TurnStartScore is the score the player's current turn started with, restored on a bust.
+	TurnStartScore int
+	Marks          CricketMarks
+	// LegsWon counts the legs won in the current set, SetsWon the sets won in the match.
+	LegsWon int
+	SetsWon int
 }
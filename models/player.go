@@ -1,7 +1,11 @@
 package models
 
+import "time"
+
 // Player represents a human player participating in matches.
 type Player struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// CreatedAt is zero for players created before it was recorded.
+	CreatedAt time.Time `json:"createdAt"`
 }
@@ -1,9 +1,19 @@
 package models
 
-// PlayerStats aggregates lifetime stats for a player across matches.
+// PlayerStats aggregates lifetime stats for a player across finished matches.
 type PlayerStats struct {
 	Pid        string `json:"pid"`
 	Matches    int    `json:"matches"`
+	Wins       int    `json:"wins"`
 	Throws     int    `json:"throws"`
 	TotalScore int    `json:"totalScore"`
+	// Rating is the player's current Elo rating.
+	Rating float64 `json:"rating"`
+}
+
+// RatingPoint is a player's rating after a rated match and the change the match caused.
+type RatingPoint struct {
+	Mid    string  `json:"mid"`
+	Rating float64 `json:"rating"`
+	Delta  float64 `json:"delta"`
 }
@@ -76,6 +76,10 @@ const (
 	// SBULL Bulls
 	SBULL
 	BULL
+
+	// MISS Darts outside the scoring area
+	MISS
+	BOUNCE_OUT
 )
 
 // ThrowScores maps each ThrowType to its points value.
@@ -101,6 +105,10 @@ var ThrowScores = map[ThrowType]int{
 	// Bulls
 	SBULL: 25,
 	BULL:  50,
+
+	// Misses
+	MISS:       0,
+	BOUNCE_OUT: 0,
 }
 
 // IsDouble returns whether the ThrowType is a possible "double"-out
@@ -113,15 +121,23 @@ func (tt ThrowType) IsMaster() bool {
 	return (tt > 20 && tt < 61) || tt == 62
 }
 
+// IsMiss returns whether the ThrowType is a dart that scored nothing (missed the board or bounced out)
+func (tt ThrowType) IsMiss() bool {
+	return tt == MISS || tt == BOUNCE_OUT
+}
+
 // ToPoints returns the point amount of the ThrowType
 func (tt ThrowType) ToPoints() int {
 	return ThrowScores[tt]
 }
 
-// GetAllThrowTypes returns all ThrowTypes for the given flags
+// GetAllThrowTypes returns all scoring ThrowTypes for the given flags
 func GetAllThrowTypes(isStraight, isDouble, isMaster bool) []ThrowType {
 	keys := make([]ThrowType, 0, len(ThrowScores))
 	for tt2 := range ThrowScores {
+		if tt2.IsMiss() {
+			continue
+		}
 		if isStraight || (isDouble && tt2.IsDouble()) || (isMaster && tt2.IsMaster()) {
 			keys = append(keys, tt2)
 		}
@@ -141,3 +157,32 @@ func GetAllThrowTypes(isStraight, isDouble, isMaster bool) []ThrowType {
 
 	return keys
 }
+
+// Segment returns the board number the ThrowType landed in (1-20, 25 for both bulls, 0 for misses).
+func (tt ThrowType) Segment() int {
+	switch {
+	case tt >= S1 && tt <= S20:
+		return int(tt - S1 + 1)
+	case tt >= D1 && tt <= D20:
+		return int(tt - D1 + 1)
+	case tt >= T1 && tt <= T20:
+		return int(tt - T1 + 1)
+	case tt == SBULL || tt == BULL:
+		return 25
+	}
+	return 0
+}
+
+// Multiplier returns how many times the segment counts (1 single, 2 double, 3 triple).
+// The outer bull counts as a single and the bull's eye as a double, misses count zero times.
+func (tt ThrowType) Multiplier() int {
+	switch {
+	case tt >= S1 && tt <= S20, tt == SBULL:
+		return 1
+	case tt >= D1 && tt <= D20, tt == BULL:
+		return 2
+	case tt >= T1 && tt <= T20:
+		return 3
+	}
+	return 0
+}
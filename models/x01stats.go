@@ -0,0 +1,19 @@
+package models
+
+// X01Stats holds PDC-style X01 metrics of a player, either for a single match or lifetime.
+type X01Stats struct {
+	// ThreeDartAverage is the mean points per three darts, busted turns score nothing.
+	ThreeDartAverage float32 `json:"threeDartAverage"`
+	// FirstNineAverage is the three-dart average over the first three turns of every leg.
+	FirstNineAverage float32 `json:"firstNineAverage"`
+	// CheckoutAttempts counts the darts thrown at a one-dart finish, Checkouts the legs won.
+	CheckoutAttempts   int     `json:"checkoutAttempts"`
+	Checkouts          int     `json:"checkouts"`
+	CheckoutPercentage float32 `json:"checkoutPercentage"`
+	// Tons counts turns of 100-139, TonForties turns of 140-179 and OneEighties maximum turns.
+	Tons        int `json:"tons"`
+	TonForties  int `json:"tonForties"`
+	OneEighties int `json:"oneEighties"`
+	// BestLeg is the fewest darts the player needed to win a leg, 0 without a won leg.
+	BestLeg int `json:"bestLeg"`
+}
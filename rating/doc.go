@@ -0,0 +1,2 @@
+// Package rating implements the Elo rating of players across multi-player matches.
+package rating
@@ -0,0 +1,38 @@
+package rating
+
+import "math"
+
+// Initial is the rating every player starts with.
+const Initial = 1500.0
+
+// K is the maximum rating change of a single 1v1 match.
+const K = 32.0
+
+// Expected returns the expected score (0-1) of a player rated a against a player rated b.
+func Expected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// Match returns the ratings after a match won by winner given the ratings of every participant
+// before the match. The winner beats every other participant; nothing is known about the order of
+// the others. K is split across the opponents, so a match moves ratings no more than a 1v1 game.
+func Match(ratings map[string]float64, winner string) map[string]float64 {
+	out := make(map[string]float64, len(ratings))
+	for pid, r := range ratings {
+		out[pid] = r
+	}
+	if _, ok := ratings[winner]; !ok || len(ratings) < 2 {
+		return out
+	}
+
+	k := K / float64(len(ratings)-1)
+	for pid, r := range ratings {
+		if pid == winner {
+			continue
+		}
+		delta := k * (1 - Expected(ratings[winner], r))
+		out[winner] += delta
+		out[pid] -= delta
+	}
+	return out
+}
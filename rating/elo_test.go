@@ -0,0 +1,50 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatch_EvenOneVersusOne(t *testing.T) {
+	after := Match(map[string]float64{"p1": Initial, "p2": Initial}, "p1")
+
+	if after["p1"] != Initial+K/2 || after["p2"] != Initial-K/2 {
+		t.Errorf("expected an even match to move both ratings by K/2, got %v", after)
+	}
+}
+
+func TestMatch_UpsetMovesMore(t *testing.T) {
+	favourite := Match(map[string]float64{"strong": 1800, "weak": 1400}, "strong")
+	upset := Match(map[string]float64{"strong": 1800, "weak": 1400}, "weak")
+
+	if gain := upset["weak"] - 1400; gain <= favourite["strong"]-1800 {
+		t.Errorf("expected an upset to gain more than an expected win, got %v vs %v", upset, favourite)
+	}
+}
+
+func TestMatch_MultiPlayerIsZeroSum(t *testing.T) {
+	before := map[string]float64{"p1": 1500, "p2": 1600, "p3": 1400}
+	after := Match(before, "p3")
+
+	sum := 0.0
+	for pid := range before {
+		sum += after[pid] - before[pid]
+	}
+	if math.Abs(sum) > 1e-9 {
+		t.Errorf("expected rating changes to sum to zero, got %f", sum)
+	}
+	if after["p3"]-before["p3"] > K {
+		t.Errorf("expected the winner to gain at most K, got %f", after["p3"]-before["p3"])
+	}
+	if after["p1"] >= before["p1"] || after["p2"] >= before["p2"] {
+		t.Errorf("expected every loser to drop, got %v", after)
+	}
+}
+
+func TestMatch_UnknownWinnerKeepsRatings(t *testing.T) {
+	after := Match(map[string]float64{"p1": 1500, "p2": 1500}, "p3")
+
+	if after["p1"] != 1500 || after["p2"] != 1500 {
+		t.Errorf("expected unchanged ratings, got %v", after)
+	}
+}
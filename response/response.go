@@ -19,16 +19,25 @@ func NewBuilder() Builder {
 }
 
 // BuildPlayerThrowResponse creates a playerthrow.Response from a given match and won flag.
+// Rule specific fields are added by the game rules.
 func (i Impl) BuildPlayerThrowResponse(match *models.Match, won, notValid bool) *playerthrow.Response {
 	return &playerthrow.Response{
-		Won:            won,
-		NextThrowBy:    match.CurrentPlayer,
-		Scores:         match.Scores,
-		NotValid:       notValid,
-		PossibleFinish: getPossibleFinishForMatchPlayer(match),
+		Won:         won,
+		NextThrowBy: match.CurrentPlayer,
+		Scores:      match.Scores,
+		NotValid:    notValid,
+		LegsWon:     match.LegsWon,
+		SetsWon:     match.SetsWon,
+		Version:     match.Version,
 	}
 }
 
+// PossibleFinish returns a checkout for the current player within the throws left in the turn,
+// in throwing order, or nil if the player cannot finish this turn.
+func PossibleFinish(match *models.Match) []models.ThrowType {
+	return getPossibleFinishForMatchPlayer(match)
+}
+
 func getPossibleFinishForMatchPlayer(match *models.Match) []models.ThrowType {
 	playerScore, ok := match.Scores[match.CurrentPlayer]
 	if !ok {
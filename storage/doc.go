@@ -1,2 +1,2 @@
-// Package storage provides SQLite-backed persistence and CRUD operations for models.
+// Package storage provides the Repository used for persistence, backed by SQLite, Postgres or kept in memory.
 package storage
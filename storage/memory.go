@@ -0,0 +1,845 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"darts-counter/models"
+	"darts-counter/rating"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is a Repository keeping all data in memory, for tests and setups without a disk.
+// It behaves like Storage: every change is atomic and RunInTx rolls back everything fn changed on error.
+// Changes are serialized; a transaction blocks every other access until it ends. A change copies only
+// the tables it modifies, the others are shared with the data before it.
+type MemoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTx is set on the MemoryStore handed to a transaction, it works on a copy of the data without locking
+	inTx bool
+}
+
+// memoryData holds the rows of every table. Stored rows are never modified in place, and a fork
+// copies a table the first time it modifies it, so the data it was forked from stays a snapshot
+// that can be rolled back to.
+type memoryData struct {
+	players      map[string]playerRow
+	playerOrder  []string
+	stats        map[string]playerStatsRow
+	matches      map[string]matchRow
+	matchOrder   []string
+	matchPlayers map[string]map[string]matchPlayerRow
+	throws       []throwRow
+	ratings      []ratingRow
+	lastThrowID  int64
+	lastRatingID int64
+	owned        owned
+}
+
+// owned tells which tables a fork has copied and may modify in place. Appending to a shared slice needs
+// no copy: it never changes the elements the data it was forked from sees.
+type owned struct {
+	players, playerOrder, stats, matches, matchOrder, throws, ratings bool
+	matchPlayers                                                      bool
+	matchPlayersOf                                                    map[string]bool
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			players:      map[string]playerRow{},
+			stats:        map[string]playerStatsRow{},
+			matches:      map[string]matchRow{},
+			matchPlayers: map[string]map[string]matchPlayerRow{},
+		},
+	}
+}
+
+// fork returns data that starts out sharing every table with d.
+func (d *memoryData) fork() *memoryData {
+	c := *d
+	c.owned = owned{}
+	return &c
+}
+
+// ownMap copies the map m unless it was copied before, as told by copied.
+func ownMap[M ~map[K]V, K comparable, V any](m *M, copied *bool) {
+	if !*copied {
+		*m = maps.Clone(*m)
+		*copied = true
+	}
+}
+
+// ownSlice copies the slice s unless it was copied before, as told by copied.
+func ownSlice[S ~[]E, E any](s *S, copied *bool) {
+	if !*copied {
+		*s = slices.Clone(*s)
+		*copied = true
+	}
+}
+
+// ownMatchPlayers copies the match players of mid, and the map holding them, unless they were copied before.
+func (d *memoryData) ownMatchPlayers(mid string) map[string]matchPlayerRow {
+	ownMap(&d.matchPlayers, &d.owned.matchPlayers)
+	if mps, ok := d.matchPlayers[mid]; ok && !d.owned.matchPlayersOf[mid] {
+		if d.owned.matchPlayersOf == nil {
+			d.owned.matchPlayersOf = map[string]bool{}
+		}
+		d.matchPlayers[mid] = maps.Clone(mps)
+		d.owned.matchPlayersOf[mid] = true
+	}
+	return d.matchPlayers[mid]
+}
+
+// read runs fn on the current data.
+func (m *MemoryStore) read(fn func(d *memoryData) error) error {
+	if m.inTx {
+		return fn(m.data)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(m.data)
+}
+
+// write runs fn on a fork of the data that replaces the data if fn succeeds.
+func (m *MemoryStore) write(fn func(d *memoryData) error) error {
+	if m.inTx {
+		return fn(m.data)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.data.fork()
+	if err := fn(d); err != nil {
+		return err
+	}
+	m.data = d
+	return nil
+}
+
+// RunInTx runs fn on a fork of the data that replaces the data if fn returns nil.
+func (m *MemoryStore) RunInTx(ctx context.Context, fn func(ctx context.Context, tx Repository) error) error {
+	if m.inTx {
+		return fn(ctx, m)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := &MemoryStore{mu: m.mu, data: m.data.fork(), inTx: true}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	m.data = tx.data
+	return nil
+}
+
+// ---------- PLAYER METHODS ----------
+
+// CreatePlayer adds a player together with its stats row.
+func (m *MemoryStore) CreatePlayer(_ context.Context, name string) (*models.Player, error) {
+	p := playerRow{ID: uuid.New().String(), Name: name, CreatedAt: time.Now()}
+	err := m.write(func(d *memoryData) error {
+		ownMap(&d.players, &d.owned.players)
+		ownMap(&d.stats, &d.owned.stats)
+		d.players[p.ID] = p
+		d.playerOrder = append(d.playerOrder, p.ID)
+		if _, ok := d.stats[p.ID]; !ok {
+			d.stats[p.ID] = playerStatsRow{Pid: p.ID, Rating: rating.Initial}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toPlayer(&p), nil
+}
+
+// UpdatePlayer renames a player and returns the updated player.
+func (m *MemoryStore) UpdatePlayer(_ context.Context, id string, name string) (*models.Player, error) {
+	var p playerRow
+	err := m.write(func(d *memoryData) error {
+		var ok bool
+		if p, ok = d.players[id]; !ok {
+			return ErrNotFound
+		}
+		p.Name = name
+		ownMap(&d.players, &d.owned.players)
+		d.players[id] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toPlayer(&p), nil
+}
+
+// GetPlayers returns all players in the order they were created.
+func (m *MemoryStore) GetPlayers(_ context.Context) ([]*models.Player, error) {
+	var players []*models.Player
+	err := m.read(func(d *memoryData) error {
+		players = make([]*models.Player, 0, len(d.playerOrder))
+		for _, id := range d.playerOrder {
+			p := d.players[id]
+			players = append(players, toPlayer(&p))
+		}
+		return nil
+	})
+	return players, err
+}
+
+// GetPlayer returns a player by ID.
+func (m *MemoryStore) GetPlayer(_ context.Context, id string) (*models.Player, error) {
+	var p playerRow
+	err := m.read(func(d *memoryData) error {
+		var ok bool
+		if p, ok = d.players[id]; !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toPlayer(&p), nil
+}
+
+// DeletePlayer removes a player with its throws, match players, stats and rating history.
+func (m *MemoryStore) DeletePlayer(_ context.Context, id string) error {
+	return m.write(func(d *memoryData) error {
+		ownSlice(&d.throws, &d.owned.throws)
+		d.throws = slices.DeleteFunc(d.throws, func(t throwRow) bool { return t.Pid == id })
+		for mid, mps := range d.matchPlayers {
+			if _, ok := mps[id]; ok {
+				delete(d.ownMatchPlayers(mid), id)
+			}
+		}
+		ownMap(&d.stats, &d.owned.stats)
+		delete(d.stats, id)
+		ownSlice(&d.ratings, &d.owned.ratings)
+		d.ratings = slices.DeleteFunc(d.ratings, func(r ratingRow) bool { return r.Pid == id })
+		ownMap(&d.players, &d.owned.players)
+		delete(d.players, id)
+		ownSlice(&d.playerOrder, &d.owned.playerOrder)
+		d.playerOrder = slices.DeleteFunc(d.playerOrder, func(pid string) bool { return pid == id })
+		return nil
+	})
+}
+
+// ---------- MATCH METHODS ----------
+
+// CreateMatch creates a new match with the given players and settings.
+// legs and sets are the legs needed to win a set and the sets needed to win the match.
+func (m *MemoryStore) CreateMatch(_ context.Context, players []string, gameType models.GameType, startAt int, startMode, endMode uint8, legs, sets int) (*models.Match, error) {
+	if len(players) == 0 {
+		return nil, errors.New("no players")
+	}
+	mr := matchRow{ID: uuid.New().String(), IsActive: true, GameType: uint8(gameType), StartAt: startAt, Startmode: startMode, Endmode: endMode, CurrentPlayer: players[0], Legs: legs, Sets: sets, Version: 1, CreatedAt: time.Now()}
+	var match *models.Match
+	err := m.write(func(d *memoryData) error {
+		ownMap(&d.matches, &d.owned.matches)
+		d.matches[mr.ID] = mr
+		d.matchOrder = append(d.matchOrder, mr.ID)
+		mps := make(map[string]matchPlayerRow, len(players))
+		for seat, pid := range players {
+			mpr := matchPlayerRow{Mid: mr.ID, Pid: pid, Seat: seat, Score: startAt, TurnStartScore: startAt}
+			if gameType.IsCricket() {
+				mpr.Marks = models.CricketMarks{}
+			}
+			mps[pid] = mpr
+		}
+		ownMap(&d.matchPlayers, &d.owned.matchPlayers)
+		d.matchPlayers[mr.ID] = mps
+		match = d.match(mr)
+		return nil
+	})
+	return match, err
+}
+
+// match converts a stored match into a match model with its players.
+func (d *memoryData) match(mr matchRow) *models.Match {
+	m := toMatch(&mr)
+	for _, mp := range d.seated(mr.ID) {
+		m.Players = append(m.Players, mp.Pid)
+		m.Scores[mp.Pid] = mp.Score
+		m.LegsWon[mp.Pid] = mp.LegsWon
+		m.SetsWon[mp.Pid] = mp.SetsWon
+		if m.Marks != nil {
+			m.Marks[mp.Pid] = maps.Clone(marksOrEmpty(mp.Marks))
+		}
+	}
+	return m
+}
+
+// seated returns the match players of a match ordered by seat.
+func (d *memoryData) seated(mid string) []matchPlayerRow {
+	mps := slices.Collect(maps.Values(d.matchPlayers[mid]))
+	sort.Slice(mps, func(i, j int) bool { return mps[i].Seat < mps[j].Seat })
+	return mps
+}
+
+// matches returns the stored matches accepted by keep in the order they were created.
+func (m *MemoryStore) matches(keep func(d *memoryData, mr matchRow) bool) ([]*models.Match, error) {
+	var out []*models.Match
+	err := m.read(func(d *memoryData) error {
+		out = make([]*models.Match, 0, len(d.matchOrder))
+		for _, id := range d.matchOrder {
+			if mr := d.matches[id]; keep(d, mr) {
+				out = append(out, d.match(mr))
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// GetMatches returns all matches with their players and scores.
+func (m *MemoryStore) GetMatches(_ context.Context) ([]*models.Match, error) {
+	return m.matches(func(*memoryData, matchRow) bool { return true })
+}
+
+// GetMatch returns a single match by id.
+func (m *MemoryStore) GetMatch(_ context.Context, id string) (*models.Match, error) {
+	return m.getMatch(id, false)
+}
+
+// GetActiveMatch returns the active match by ID or an error if not active or not found.
+func (m *MemoryStore) GetActiveMatch(_ context.Context, mid string) (*models.Match, error) {
+	return m.getMatch(mid, true)
+}
+
+func (m *MemoryStore) getMatch(id string, active bool) (*models.Match, error) {
+	var match *models.Match
+	err := m.read(func(d *memoryData) error {
+		mr, ok := d.matches[id]
+		if !ok || (active && !mr.IsActive) {
+			return ErrNotFound
+		}
+		match = d.match(mr)
+		return nil
+	})
+	return match, err
+}
+
+// UpdateMatch persists the mutable fields of a match and bumps its version. It returns ErrVersionConflict
+// if the match was changed since it was loaded.
+func (m *MemoryStore) UpdateMatch(_ context.Context, match *models.Match) error {
+	err := m.write(func(d *memoryData) error {
+		mr, ok := d.matches[match.ID]
+		if !ok || mr.Version != match.Version {
+			return ErrVersionConflict
+		}
+		mr.CurrentPlayer = match.CurrentPlayer
+		mr.CurrentThrow = int(match.CurrentThrow)
+		mr.Leg = match.Leg
+		mr.Version++
+		ownMap(&d.matches, &d.owned.matches)
+		d.matches[match.ID] = mr
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	match.Version++
+	return nil
+}
+
+// DeleteMatch removes a match with its match players and throws.
+func (m *MemoryStore) DeleteMatch(_ context.Context, id string) error {
+	return m.write(func(d *memoryData) error {
+		pids := d.matchPids(id)
+		rated := d.isRated(id)
+		ownSlice(&d.throws, &d.owned.throws)
+		d.throws = slices.DeleteFunc(d.throws, func(t throwRow) bool { return t.Mid == id })
+		ownMap(&d.matchPlayers, &d.owned.matchPlayers)
+		delete(d.matchPlayers, id)
+		ownMap(&d.matches, &d.owned.matches)
+		delete(d.matches, id)
+		ownSlice(&d.matchOrder, &d.owned.matchOrder)
+		d.matchOrder = slices.DeleteFunc(d.matchOrder, func(mid string) bool { return mid == id })
+		d.refreshPlayerStats(pids...)
+		if rated {
+			d.recomputeRatings()
+		}
+		return nil
+	})
+}
+
+// WonMatch marks a match as finished, stores the winner (WonBy, or the current player if unset)
+// and refreshes the player stats and ratings of everyone in the match. A match won again keeps its
+// FinishedAt.
+func (m *MemoryStore) WonMatch(_ context.Context, match *models.Match) error {
+	return m.write(func(d *memoryData) error {
+		mr, ok := d.matches[match.ID]
+		if !ok {
+			return nil
+		}
+		winner := match.WonBy
+		if winner == "" {
+			winner = match.CurrentPlayer
+		}
+		mr.IsActive = false
+		mr.WonBy = &winner
+		// a match won again after an edit keeps the time it was first finished
+		if mr.FinishedAt.IsZero() {
+			mr.FinishedAt = time.Now()
+		}
+		ownMap(&d.matches, &d.owned.matches)
+		d.matches[match.ID] = mr
+		d.refreshPlayerStats(d.matchPids(match.ID)...)
+
+		if d.isRated(match.ID) {
+			d.recomputeRatings()
+		} else {
+			d.rateMatch(match.ID, winner)
+		}
+		return nil
+	})
+}
+
+// ReopenMatch marks a finished match as active again and clears its winner.
+func (m *MemoryStore) ReopenMatch(_ context.Context, mid string) error {
+	return m.write(func(d *memoryData) error {
+		mr, ok := d.matches[mid]
+		if !ok {
+			return nil
+		}
+		mr.IsActive = true
+		mr.WonBy = nil
+		mr.FinishedAt = time.Time{}
+		ownMap(&d.matches, &d.owned.matches)
+		d.matches[mid] = mr
+		d.refreshPlayerStats(d.matchPids(mid)...)
+		if d.isRated(mid) {
+			d.recomputeRatings()
+		}
+		return nil
+	})
+}
+
+// GetFinishedMatches returns the matches that have a winner and were finished at or after since.
+// A zero since returns every finished match.
+func (m *MemoryStore) GetFinishedMatches(_ context.Context, since time.Time) ([]*models.Match, error) {
+	return m.matches(func(_ *memoryData, mr matchRow) bool {
+		return !mr.IsActive && mr.WonBy != nil && (since.IsZero() || !mr.FinishedAt.Before(since))
+	})
+}
+
+// GetPlayerMatches returns every match the player takes part in.
+func (m *MemoryStore) GetPlayerMatches(ctx context.Context, pid string) ([]*models.Match, error) {
+	return m.GetSharedMatches(ctx, pid)
+}
+
+// GetSharedMatches returns every match all of the given players take part in.
+func (m *MemoryStore) GetSharedMatches(_ context.Context, pids ...string) ([]*models.Match, error) {
+	return m.matches(func(d *memoryData, mr matchRow) bool {
+		for _, pid := range pids {
+			if _, ok := d.matchPlayers[mr.ID][pid]; !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// ---------- MATCH_PLAYER METHODS ----------
+
+// GetMatchPlayerModel returns the match player of a given match and player.
+func (m *MemoryStore) GetMatchPlayerModel(_ context.Context, mid, pid string) (*models.MatchPlayer, error) {
+	var mp *models.MatchPlayer
+	err := m.read(func(d *memoryData) error {
+		mpr, ok := d.matchPlayers[mid][pid]
+		if !ok {
+			return ErrNotFound
+		}
+		mp = toMemoryMatchPlayer(mpr)
+		return nil
+	})
+	return mp, err
+}
+
+// GetAllMatchPlayers returns the match players of a match ordered by seat.
+func (m *MemoryStore) GetAllMatchPlayers(_ context.Context, mid string) ([]*models.MatchPlayer, error) {
+	var out []*models.MatchPlayer
+	err := m.read(func(d *memoryData) error {
+		mps := d.seated(mid)
+		out = make([]*models.MatchPlayer, 0, len(mps))
+		for _, mpr := range mps {
+			out = append(out, toMemoryMatchPlayer(mpr))
+		}
+		return nil
+	})
+	return out, err
+}
+
+// UpdateMatchPlayer persists the state of a match player and returns it.
+func (m *MemoryStore) UpdateMatchPlayer(_ context.Context, mp *models.MatchPlayer) (*models.MatchPlayer, error) {
+	if mp == nil || mp.Mid == "" || mp.Pid == "" {
+		return nil, errors.New("invalid match player model")
+	}
+	var updated *models.MatchPlayer
+	err := m.write(func(d *memoryData) error {
+		mpr, ok := d.matchPlayers[mp.Mid][mp.Pid]
+		if !ok {
+			return ErrNotFound
+		}
+		mpr.OverallThrows = mp.OverallThrows
+		mpr.Score = mp.Score
+		mpr.TurnStartScore = mp.TurnStartScore
+		mpr.Marks = maps.Clone(mp.Marks)
+		mpr.LegsWon = mp.LegsWon
+		mpr.SetsWon = mp.SetsWon
+		d.ownMatchPlayers(mp.Mid)[mp.Pid] = mpr
+		updated = toMemoryMatchPlayer(mpr)
+		return nil
+	})
+	return updated, err
+}
+
+// toMemoryMatchPlayer converts a stored match player, the model gets its own marks.
+func toMemoryMatchPlayer(mpr matchPlayerRow) *models.MatchPlayer {
+	mpr.Marks = maps.Clone(mpr.Marks)
+	return toMatchPlayer(&mpr)
+}
+
+// ---------- THROW METHODS ----------
+
+// CreateThrow records a throw as part of the thrower's next unfinished turn and starts the match with its first throw.
+func (m *MemoryStore) CreateThrow(_ context.Context, tr ThrowRecord) (*ThrowRecord, error) {
+	if tr.Mid == "" || tr.Pid == "" {
+		return nil, errors.New("empty ids")
+	}
+	if tr.ThrownAt.IsZero() {
+		tr.ThrownAt = time.Now()
+	}
+	var created *ThrowRecord
+	err := m.write(func(d *memoryData) error {
+		tr.Turn = 1
+		for _, t := range d.throws {
+			if t.Mid == tr.Mid && t.Pid == tr.Pid && t.EndedTurn {
+				tr.Turn++
+			}
+		}
+		d.lastThrowID++
+		row := throwRow{ID: d.lastThrowID, Mid: tr.Mid, Pid: tr.Pid, ThrowType: tr.ThrowType, EndedTurn: tr.EndedTurn, Busted: tr.Busted, Turn: tr.Turn, Leg: tr.Leg, ThrownAt: tr.ThrownAt}
+		d.throws = append(d.throws, row)
+
+		if mr, ok := d.matches[tr.Mid]; ok && mr.StartedAt.IsZero() {
+			mr.StartedAt = tr.ThrownAt
+			ownMap(&d.matches, &d.owned.matches)
+			d.matches[tr.Mid] = mr
+		}
+		created = toThrowRecord(&row)
+		return nil
+	})
+	return created, err
+}
+
+// GetThrows returns all throws of a match in the order they were thrown.
+func (m *MemoryStore) GetThrows(_ context.Context, mid string) ([]*ThrowRecord, error) {
+	var out []*ThrowRecord
+	err := m.read(func(d *memoryData) error {
+		out = []*ThrowRecord{}
+		for i := range d.throws {
+			if d.throws[i].Mid == mid {
+				out = append(out, toThrowRecord(&d.throws[i]))
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// GetLastThrow returns the latest throw of a match.
+func (m *MemoryStore) GetLastThrow(_ context.Context, mid string) (*ThrowRecord, error) {
+	var last *ThrowRecord
+	err := m.read(func(d *memoryData) error {
+		for i := len(d.throws) - 1; i >= 0; i-- {
+			if d.throws[i].Mid == mid {
+				last = toThrowRecord(&d.throws[i])
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return last, err
+}
+
+// GetThrow returns a throw by ID.
+func (m *MemoryStore) GetThrow(_ context.Context, id int64) (*ThrowRecord, error) {
+	var tr *ThrowRecord
+	err := m.read(func(d *memoryData) error {
+		i := d.throwIndex(id)
+		if i < 0 {
+			return ErrNotFound
+		}
+		tr = toThrowRecord(&d.throws[i])
+		return nil
+	})
+	return tr, err
+}
+
+func (d *memoryData) throwIndex(id int64) int {
+	i, found := slices.BinarySearchFunc(d.throws, id, func(t throwRow, id int64) int {
+		switch {
+		case t.ID < id:
+			return -1
+		case t.ID > id:
+			return 1
+		}
+		return 0
+	})
+	if !found {
+		return -1
+	}
+	return i
+}
+
+// UpdateThrow overwrites a recorded throw, keeping the time it was thrown.
+func (m *MemoryStore) UpdateThrow(_ context.Context, tr *ThrowRecord) (*ThrowRecord, error) {
+	if tr == nil || tr.ID == 0 {
+		return nil, errors.New("invalid throw model")
+	}
+	var updated *ThrowRecord
+	err := m.write(func(d *memoryData) error {
+		i := d.throwIndex(tr.ID)
+		if i < 0 {
+			return ErrNotFound
+		}
+		row := d.throws[i]
+		row.Mid, row.Pid, row.ThrowType = tr.Mid, tr.Pid, tr.ThrowType
+		row.EndedTurn, row.Busted, row.Turn, row.Leg = tr.EndedTurn, tr.Busted, tr.Turn, tr.Leg
+		ownSlice(&d.throws, &d.owned.throws)
+		d.throws[i] = row
+		updated = toThrowRecord(&row)
+		return nil
+	})
+	return updated, err
+}
+
+// DeleteThrow removes a throw.
+func (m *MemoryStore) DeleteThrow(_ context.Context, id int64) error {
+	return m.write(func(d *memoryData) error {
+		if i := d.throwIndex(id); i >= 0 {
+			ownSlice(&d.throws, &d.owned.throws)
+			d.throws = slices.Delete(d.throws, i, i+1)
+		}
+		return nil
+	})
+}
+
+// BustTurn marks every throw of a player's turn as busted.
+func (m *MemoryStore) BustTurn(_ context.Context, mid, pid string, turn int) error {
+	return m.write(func(d *memoryData) error {
+		for i, t := range d.throws {
+			if t.Mid == mid && t.Pid == pid && t.Turn == turn {
+				ownSlice(&d.throws, &d.owned.throws)
+				d.throws[i].Busted = true
+			}
+		}
+		return nil
+	})
+}
+
+// GetHistory returns all throws of every player in a match, latest first.
+func (m *MemoryStore) GetHistory(_ context.Context, match *models.Match) (*models.History, error) {
+	return m.history(match, func(throwRow, []throwRow) bool { return true })
+}
+
+// GetLastTurnHistory returns the throws of the latest turn of every player in the current leg, latest first.
+func (m *MemoryStore) GetLastTurnHistory(_ context.Context, match *models.Match) (*models.History, error) {
+	return m.history(match, func(t throwRow, leg []throwRow) bool {
+		if t.Leg != match.Leg {
+			return false
+		}
+		last := 0
+		for _, o := range leg {
+			if o.Pid == t.Pid && o.Leg == match.Leg {
+				last = max(last, o.Turn)
+			}
+		}
+		return t.Turn == last
+	})
+}
+
+func (m *MemoryStore) history(match *models.Match, keep func(t throwRow, throws []throwRow) bool) (*models.History, error) {
+	history := models.History{History: make(map[string][]models.HistoryElement, len(match.Players))}
+	err := m.read(func(d *memoryData) error {
+		var throws []throwRow
+		for _, t := range d.throws {
+			if t.Mid == match.ID {
+				throws = append(throws, t)
+			}
+		}
+		for _, pid := range match.Players {
+			var rows []throwRow
+			for i := len(throws) - 1; i >= 0; i-- {
+				if throws[i].Pid == pid && keep(throws[i], throws) {
+					rows = append(rows, throws[i])
+				}
+			}
+			addHistoryItem(rows, history, pid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// ---------- STATS AND RATINGS ----------
+
+// GetPlayerStats returns the stats of a player.
+func (m *MemoryStore) GetPlayerStats(_ context.Context, pid string) (*models.PlayerStats, error) {
+	var ps *models.PlayerStats
+	err := m.read(func(d *memoryData) error {
+		r, ok := d.stats[pid]
+		if !ok {
+			return ErrNotFound
+		}
+		ps = toPlayerStats(&r)
+		return nil
+	})
+	return ps, err
+}
+
+// GetAllPlayerStats returns the stats of every player.
+func (m *MemoryStore) GetAllPlayerStats(_ context.Context) ([]*models.PlayerStats, error) {
+	var out []*models.PlayerStats
+	err := m.read(func(d *memoryData) error {
+		out = make([]*models.PlayerStats, 0, len(d.stats))
+		for _, pid := range d.playerOrder {
+			if r, ok := d.stats[pid]; ok {
+				out = append(out, toPlayerStats(&r))
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// GetRatingHistory returns the rating of a player after each rated match in the order they were rated.
+func (m *MemoryStore) GetRatingHistory(_ context.Context, pid string) ([]models.RatingPoint, error) {
+	var out []models.RatingPoint
+	err := m.read(func(d *memoryData) error {
+		out = []models.RatingPoint{}
+		for _, r := range d.ratings {
+			if r.Pid == pid {
+				out = append(out, models.RatingPoint{Mid: r.Mid, Rating: r.Rating, Delta: r.Delta})
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// RecomputeRatings resets every rating and rates all finished matches again in the order they were finished.
+func (m *MemoryStore) RecomputeRatings(_ context.Context) error {
+	return m.write(func(d *memoryData) error {
+		d.recomputeRatings()
+		return nil
+	})
+}
+
+func (d *memoryData) matchPids(mid string) []string {
+	var pids []string
+	for _, mp := range d.seated(mid) {
+		pids = append(pids, mp.Pid)
+	}
+	return pids
+}
+
+// refreshPlayerStats recomputes the stats of each player from their finished matches,
+// like Storage.refreshPlayerStats.
+func (d *memoryData) refreshPlayerStats(pids ...string) {
+	ownMap(&d.stats, &d.owned.stats)
+	for _, pid := range pids {
+		ps, ok := d.stats[pid]
+		if !ok {
+			ps = playerStatsRow{Pid: pid, Rating: rating.Initial}
+		}
+		ps.Matches, ps.Wins, ps.Throws, ps.TotalScore = 0, 0, 0, 0
+		for mid, mps := range d.matchPlayers {
+			if _, ok := mps[pid]; ok && !d.matches[mid].IsActive {
+				ps.Matches++
+			}
+		}
+		for _, mr := range d.matches {
+			if mr.WonBy != nil && *mr.WonBy == pid {
+				ps.Wins++
+			}
+		}
+		for _, t := range d.throws {
+			mr, ok := d.matches[t.Mid]
+			if t.Pid != pid || !ok || mr.IsActive {
+				continue
+			}
+			ps.Throws++
+			if models.GameType(mr.GameType) == models.X01 && !t.Busted {
+				ps.TotalScore += models.ThrowType(t.ThrowType).ToPoints()
+			}
+		}
+		d.stats[pid] = ps
+	}
+}
+
+func (d *memoryData) isRated(mid string) bool {
+	return slices.ContainsFunc(d.ratings, func(r ratingRow) bool { return r.Mid == mid })
+}
+
+// recomputeRatings rates every finished match again ordered by its last throw, like Storage.recomputeRatings.
+func (d *memoryData) recomputeRatings() {
+	d.ratings = nil
+	ownMap(&d.stats, &d.owned.stats)
+	for pid, ps := range d.stats {
+		ps.Rating = rating.Initial
+		d.stats[pid] = ps
+	}
+
+	lastThrow := map[string]int64{}
+	for _, t := range d.throws {
+		lastThrow[t.Mid] = t.ID
+	}
+	var finished []matchRow
+	for _, id := range d.matchOrder {
+		if mr := d.matches[id]; !mr.IsActive && mr.WonBy != nil {
+			finished = append(finished, mr)
+		}
+	}
+	sort.SliceStable(finished, func(i, j int) bool { return lastThrow[finished[i].ID] < lastThrow[finished[j].ID] })
+	for _, mr := range finished {
+		d.rateMatch(mr.ID, *mr.WonBy)
+	}
+}
+
+// rateMatch applies the rating changes of a match won by winner to everyone in it and records them.
+func (d *memoryData) rateMatch(mid, winner string) {
+	pids := d.matchPids(mid)
+	before := make(map[string]float64, len(pids))
+	for _, pid := range pids {
+		before[pid] = rating.Initial
+		if ps, ok := d.stats[pid]; ok {
+			before[pid] = ps.Rating
+		}
+	}
+
+	after := rating.Match(before, winner)
+	ownMap(&d.stats, &d.owned.stats)
+	for _, pid := range pids {
+		ps, ok := d.stats[pid]
+		if !ok {
+			ps = playerStatsRow{Pid: pid}
+		}
+		ps.Rating = after[pid]
+		d.stats[pid] = ps
+		d.lastRatingID++
+		d.ratings = append(d.ratings, ratingRow{ID: d.lastRatingID, Pid: pid, Mid: mid, Rating: after[pid], Delta: after[pid] - before[pid]})
+	}
+}
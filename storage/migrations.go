@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/migrate"
+	_ "modernc.org/sqlite"
+)
+
+// SchemaVersionTable records the applied migrations.
+const SchemaVersionTable = "schema_version"
+
+// migrations upgrade a database step by step from the original schema. Databases created before
+// migrations existed already have the original tables, and possibly some of the later columns,
+// so every step only adds what is missing. Append new steps, never change applied ones.
+// Every step has to work on SQLite and Postgres; quote camelCase identifiers, Postgres folds unquoted ones.
+var migrations = migrate.NewMigrations()
+
+// column is a column added by a migration.
+type column struct {
+	table, name, definition string
+}
+
+func init() {
+	// the original tables; rolling it back is a no-op as it would drop all data
+	addMigration("0001_initial", "original tables",
+		func(ctx context.Context, db bun.IDB) error {
+			for _, q := range []string{
+				`CREATE TABLE IF NOT EXISTS "players" ("id" VARCHAR NOT NULL, "name" VARCHAR NOT NULL, PRIMARY KEY ("id"))`,
+				`CREATE TABLE IF NOT EXISTS "player_stats" ("pid" VARCHAR NOT NULL, "matches" INTEGER NOT NULL DEFAULT 0, "throws" INTEGER NOT NULL DEFAULT 0, "totalScore" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY ("pid"))`,
+				`CREATE TABLE IF NOT EXISTS "matches" ("id" VARCHAR NOT NULL, "isActive" BOOLEAN NOT NULL DEFAULT true, "startAt" INTEGER NOT NULL, "startmode" INTEGER NOT NULL, "endmode" INTEGER NOT NULL, "currentPlayer" VARCHAR, "currentThrow" INTEGER NOT NULL DEFAULT 0, "wonBy" VARCHAR, PRIMARY KEY ("id"))`,
+				`CREATE TABLE IF NOT EXISTS "match_players" ("mid" VARCHAR NOT NULL, "pid" VARCHAR NOT NULL, "overallThrows" INTEGER NOT NULL DEFAULT 0, "score" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY ("mid", "pid"))`,
+				`CREATE TABLE IF NOT EXISTS "match_player_throws" (` + idColumn(db) + `, "mid" VARCHAR, "pid" VARCHAR, "throw_type" INTEGER, "turn" INTEGER, "endedTurn" BOOLEAN NOT NULL DEFAULT false)`,
+			} {
+				if _, err := db.ExecContext(ctx, q); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		func(context.Context, bun.IDB) error { return nil })
+
+	addColumnsMigration("0002_game_types", "cricket game types and marks",
+		column{"matches", "gameType", "INTEGER NOT NULL DEFAULT 0"},
+		column{"match_players", "marks", "json"},
+	)
+
+	addColumnsMigration("0003_turns", "seats, turn start scores and busted throws",
+		column{"match_players", "seat", "INTEGER NOT NULL DEFAULT 0"},
+		column{"match_players", "turnStartScore", "INTEGER NOT NULL DEFAULT 0"},
+		column{"match_player_throws", "busted", "BOOLEAN NOT NULL DEFAULT false"},
+	)
+	// players were stored in throwing order, so the insertion order gives the seats of existing matches.
+	// Only SQLite databases predate seats, Postgres has no rowid to order by.
+	addMigration("0004_seats", "seats of existing matches",
+		func(ctx context.Context, db bun.IDB) error {
+			if db.Dialect().Name() != dialect.SQLite {
+				return nil
+			}
+			_, err := db.ExecContext(ctx, `UPDATE "match_players" SET "seat" = (SELECT COUNT(*) FROM "match_players" AS o WHERE o."mid" = "match_players"."mid" AND o.rowid < "match_players".rowid)`)
+			return err
+		},
+		func(context.Context, bun.IDB) error { return nil })
+
+	addColumnsMigration("0005_legs_and_sets", "legs and sets formats",
+		column{"matches", "legs", "INTEGER NOT NULL DEFAULT 1"},
+		column{"matches", "sets", "INTEGER NOT NULL DEFAULT 1"},
+		column{"matches", "leg", "INTEGER NOT NULL DEFAULT 0"},
+		column{"match_players", "legsWon", "INTEGER NOT NULL DEFAULT 0"},
+		column{"match_players", "setsWon", "INTEGER NOT NULL DEFAULT 0"},
+		column{"match_player_throws", "leg", "INTEGER NOT NULL DEFAULT 0"},
+	)
+
+	addColumnsMigration("0006_wins", "wins in player stats",
+		column{"player_stats", "wins", "INTEGER NOT NULL DEFAULT 0"},
+	)
+	addDataMigration("0007_count_wins", "wins of existing matches",
+		`UPDATE "player_stats" SET "wins" = (SELECT COUNT(*) FROM "matches" WHERE "matches"."wonBy" = "player_stats"."pid" AND "matches"."isActive" = false)`)
+
+	// matches finished before are rated by the ratings command
+	addColumnsMigration("0008_ratings", "player ratings",
+		column{"player_stats", "rating", "DOUBLE PRECISION NOT NULL DEFAULT 1500"},
+	)
+	addMigration("0009_rating_history", "rating history",
+		func(ctx context.Context, db bun.IDB) error {
+			_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "rating_history" (`+idColumn(db)+`, "pid" VARCHAR, "mid" VARCHAR, "rating" DOUBLE PRECISION NOT NULL, "delta" DOUBLE PRECISION NOT NULL)`)
+			return err
+		},
+		func(ctx context.Context, db bun.IDB) error {
+			_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS "rating_history"`)
+			return err
+		})
+
+	addColumnsMigration("0010_timestamps", "creation, start, finish and throw timestamps",
+		column{"players", "createdAt", "TIMESTAMP"},
+		column{"matches", "createdAt", "TIMESTAMP"},
+		column{"matches", "startedAt", "TIMESTAMP"},
+		column{"matches", "finishedAt", "TIMESTAMP"},
+		column{"match_player_throws", "thrownAt", "TIMESTAMP"},
+	)
+
+	addColumnsMigration("0011_versions", "match versions for optimistic locking",
+		column{"matches", "version", "INTEGER NOT NULL DEFAULT 1"},
+	)
+}
+
+// addMigration registers a migration whose up and down steps each run in a transaction.
+func addMigration(name, comment string, up, down func(ctx context.Context, db bun.IDB) error) {
+	inTx := func(fn func(ctx context.Context, db bun.IDB) error) func(context.Context, *bun.DB, any) error {
+		return func(ctx context.Context, db *bun.DB, _ any) error {
+			return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				return fn(ctx, tx)
+			})
+		}
+	}
+	migrations.Add(migrate.Migration{Name: name, Comment: comment, Up: inTx(up), Down: inTx(down)})
+}
+
+// addColumnsMigration registers a migration adding columns that do not exist yet. Rolling it back drops them.
+func addColumnsMigration(name, comment string, columns ...column) {
+	addMigration(name, comment,
+		func(ctx context.Context, db bun.IDB) error {
+			for _, c := range columns {
+				exists, err := hasColumn(ctx, db, c.table, c.name)
+				if err != nil {
+					return err
+				}
+				if exists {
+					continue
+				}
+				if _, err := db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %q ADD COLUMN %q %s`, c.table, c.name, c.definition)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		func(ctx context.Context, db bun.IDB) error {
+			for i := len(columns) - 1; i >= 0; i-- {
+				c := columns[i]
+				exists, err := hasColumn(ctx, db, c.table, c.name)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					continue
+				}
+				if _, err := db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %q DROP COLUMN %q`, c.table, c.name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// addDataMigration registers a migration that only updates rows. There is nothing to roll back.
+func addDataMigration(name, comment, query string) {
+	addMigration(name, comment,
+		func(ctx context.Context, db bun.IDB) error {
+			_, err := db.ExecContext(ctx, query)
+			return err
+		},
+		func(context.Context, bun.IDB) error { return nil })
+}
+
+func hasColumn(ctx context.Context, db bun.IDB, table, name string) (bool, error) {
+	query := "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?"
+	if db.Dialect().Name() == dialect.PG {
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+	}
+	var n int
+	err := db.NewRaw(query, table, name).Scan(ctx, &n)
+	return n > 0, err
+}
+
+// idColumn is the definition of an auto-incrementing "id" primary key.
+func idColumn(db bun.IDB) string {
+	if db.Dialect().Name() == dialect.PG {
+		return `"id" BIGSERIAL PRIMARY KEY`
+	}
+	return `"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT`
+}
+
+// IsPostgres reports whether dsn is a Postgres connection URL rather than an SQLite file.
+func IsPostgres(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// OpenDB opens the database of dsn without touching its schema. A postgres:// or postgresql:// URL
+// opens a Postgres database, anything else is the path of an SQLite database file.
+// SQLite transactions take the write lock when they begin and wait for each other instead of failing as busy.
+func OpenDB(dsn string) (*bun.DB, error) {
+	if IsPostgres(dsn) {
+		return openPostgres(dsn)
+	}
+
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_pragma=busy_timeout(5000)&_txlock=immediate"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return bun.NewDB(db, sqlitedialect.New()), nil
+}
+
+// NewMigrator returns a migrator for the schema of db that records applied migrations in SchemaVersionTable.
+func NewMigrator(db *bun.DB) *migrate.Migrator {
+	return migrate.NewMigrator(db, migrations,
+		migrate.WithTableName(SchemaVersionTable),
+		migrate.WithLocksTableName(SchemaVersionTable+"_lock"),
+		migrate.WithMarkAppliedOnSuccess(true),
+	)
+}
+
+// Migrate applies every pending migration to db.
+func Migrate(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	migrator := NewMigrator(db)
+	if err := migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+	return migrator.Migrate(ctx)
+}
+
+func openPostgres(dsn string) (db *bun.DB, err error) {
+	// the connector parses the DSN when it is created and panics on a malformed one
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid postgres dsn: %v", r)
+		}
+	}()
+	return bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn))), pgdialect.New()), nil
+}
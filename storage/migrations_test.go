@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// a database written by the version before migrations: tables without any of the later columns
+var originalSchema = []string{
+	`CREATE TABLE "players" ("id" VARCHAR NOT NULL, "name" VARCHAR NOT NULL, PRIMARY KEY ("id"))`,
+	`CREATE TABLE "player_stats" ("pid" VARCHAR NOT NULL, "matches" INTEGER NOT NULL DEFAULT 0, "throws" INTEGER NOT NULL DEFAULT 0, "totalScore" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY ("pid"))`,
+	`CREATE TABLE "matches" ("id" VARCHAR NOT NULL, "isActive" BOOLEAN NOT NULL DEFAULT true, "startAt" INTEGER NOT NULL, "startmode" INTEGER NOT NULL, "endmode" INTEGER NOT NULL, "currentPlayer" VARCHAR, "currentThrow" INTEGER NOT NULL DEFAULT 0, "wonBy" VARCHAR, PRIMARY KEY ("id"))`,
+	`CREATE TABLE "match_players" ("mid" VARCHAR NOT NULL, "pid" VARCHAR NOT NULL, "overallThrows" INTEGER NOT NULL DEFAULT 0, "score" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY ("mid", "pid"))`,
+	`CREATE TABLE "match_player_throws" ("id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, "mid" VARCHAR, "pid" VARCHAR, "throw_type" INTEGER, "turn" INTEGER, "endedTurn" BOOLEAN NOT NULL DEFAULT false)`,
+	`INSERT INTO "players" VALUES ('b', 'Bob'), ('a', 'Alice')`,
+	`INSERT INTO "player_stats" ("pid", "matches") VALUES ('a', 1), ('b', 1)`,
+	`INSERT INTO "matches" VALUES ('m', false, 301, 0, 1, 'b', 0, 'b')`,
+	`INSERT INTO "match_players" VALUES ('m', 'b', 10, 0), ('m', 'a', 9, 40)`,
+}
+
+func TestMigrate_UpgradesOriginalSchema(t *testing.T) {
+	ctx := context.Background()
+	dbFile := filepath.Join(t.TempDir(), "darts.db")
+	db, err := OpenDB(dbFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, q := range originalSchema {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	db.Close()
+
+	s := NewStorage(dbFile)
+	match, err := s.GetMatch(ctx, "m")
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if len(match.Players) != 2 || match.Players[0] != "b" || match.Players[1] != "a" {
+		t.Errorf("expected the players in their original order, got %v", match.Players)
+	}
+	if match.Legs != 1 || match.Sets != 1 || match.WonBy != "b" || match.FinishedAt != nil {
+		t.Errorf("unexpected upgraded match %+v", match)
+	}
+	stats, err := s.GetPlayerStats(ctx, "b")
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if stats.Wins != 1 || stats.Rating != 1500 {
+		t.Errorf("expected one win and the initial rating, got %+v", stats)
+	}
+	if _, err := s.CreatePlayer(ctx, "Carol"); err != nil {
+		t.Errorf("create player on the upgraded schema: %v", err)
+	}
+}
+
+func TestMigrate_RollbackAndReapply(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage(filepath.Join(t.TempDir(), "darts.db"))
+	migrator := NewMigrator(s.Bun)
+
+	if _, err := migrator.Rollback(ctx); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if exists, err := hasColumn(ctx, s.Bun, "matches", "legs"); err != nil || exists {
+		t.Errorf("expected the legs column to be dropped, exists=%v err=%v", exists, err)
+	}
+	applied, err := migrator.AppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("applied migrations: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no applied migrations, got %s", applied)
+	}
+
+	group, err := migrator.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if len(group.Migrations) != len(migrations.Sorted()) {
+		t.Errorf("expected every migration to be applied again, got %s", group)
+	}
+	if _, err := s.CreatePlayer(ctx, "Alice"); err != nil {
+		t.Errorf("create player after reapplying: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"darts-counter/models"
+)
+
+// Repository is the persistence used by the darts service and the HTTP handlers.
+// Storage keeps the data in an SQLite or Postgres database, MemoryStore in memory.
+// Lookups of a player, match, match player, throw or stats row that does not exist fail with ErrNotFound.
+type Repository interface {
+	// RunInTx runs fn in a transaction that is committed if fn returns nil and rolled back otherwise.
+	// The Repository passed to fn uses the transaction; inside a transaction fn joins the current one.
+	RunInTx(ctx context.Context, fn func(ctx context.Context, tx Repository) error) error
+
+	// players
+	CreatePlayer(ctx context.Context, name string) (*models.Player, error)
+	UpdatePlayer(ctx context.Context, id string, name string) (*models.Player, error)
+	GetPlayers(ctx context.Context) ([]*models.Player, error)
+	GetPlayer(ctx context.Context, id string) (*models.Player, error)
+	DeletePlayer(ctx context.Context, id string) error
+
+	// matches
+	CreateMatch(ctx context.Context, players []string, gameType models.GameType, startAt int, startMode, endMode uint8, legs, sets int) (*models.Match, error)
+	GetMatches(ctx context.Context) ([]*models.Match, error)
+	GetMatch(ctx context.Context, id string) (*models.Match, error)
+	GetActiveMatch(ctx context.Context, mid string) (*models.Match, error)
+	UpdateMatch(ctx context.Context, match *models.Match) error
+	DeleteMatch(ctx context.Context, id string) error
+	WonMatch(ctx context.Context, match *models.Match) error
+	ReopenMatch(ctx context.Context, mid string) error
+	GetFinishedMatches(ctx context.Context, since time.Time) ([]*models.Match, error)
+	GetPlayerMatches(ctx context.Context, pid string) ([]*models.Match, error)
+	GetSharedMatches(ctx context.Context, pids ...string) ([]*models.Match, error)
+
+	// match players
+	GetMatchPlayerModel(ctx context.Context, mid, pid string) (*models.MatchPlayer, error)
+	GetAllMatchPlayers(ctx context.Context, mid string) ([]*models.MatchPlayer, error)
+	UpdateMatchPlayer(ctx context.Context, mp *models.MatchPlayer) (*models.MatchPlayer, error)
+
+	// throws
+	CreateThrow(ctx context.Context, tr ThrowRecord) (*ThrowRecord, error)
+	GetThrows(ctx context.Context, mid string) ([]*ThrowRecord, error)
+	GetLastThrow(ctx context.Context, mid string) (*ThrowRecord, error)
+	GetThrow(ctx context.Context, id int64) (*ThrowRecord, error)
+	UpdateThrow(ctx context.Context, tr *ThrowRecord) (*ThrowRecord, error)
+	DeleteThrow(ctx context.Context, id int64) error
+	BustTurn(ctx context.Context, mid, pid string, turn int) error
+	GetHistory(ctx context.Context, match *models.Match) (*models.History, error)
+	GetLastTurnHistory(ctx context.Context, match *models.Match) (*models.History, error)
+
+	// stats and ratings
+	GetPlayerStats(ctx context.Context, pid string) (*models.PlayerStats, error)
+	GetAllPlayerStats(ctx context.Context) ([]*models.PlayerStats, error)
+	GetRatingHistory(ctx context.Context, pid string) ([]models.RatingPoint, error)
+	RecomputeRatings(ctx context.Context) error
+}
+
+// ErrNotFound is returned by lookups of a row that does not exist. It matches sql.ErrNoRows as well.
+var ErrNotFound error = notFoundError{}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string { return "not found" }
+
+func (notFoundError) Is(target error) bool { return target == sql.ErrNoRows }
+
+// notFound turns the sql.ErrNoRows of a lookup into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+var (
+	_ Repository = (*Storage)(nil)
+	_ Repository = (*MemoryStore)(nil)
+)
@@ -2,353 +2,476 @@ package storage
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"log"
+	"time"
 
 	"darts-counter/models"
+	"darts-counter/rating"
 
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/dialect/sqlitedialect"
-	_ "modernc.org/sqlite"
 )
 
-// Storage wraps a Bun DB for interacting with SQLite-backed persistence.
+// ErrVersionConflict is returned when a match was changed since it was loaded.
+var ErrVersionConflict = errors.New("match was changed concurrently")
+
+// Storage wraps a Bun DB for interacting with SQLite- or Postgres-backed persistence.
+// A Storage handed out by RunInTx runs every statement in that transaction.
 type Storage struct {
 	Bun *bun.DB
+	tx  bun.IDB
 }
 
-// NewStorage creates a new SQLite service using Bun only
-func NewStorage(dbFile string) *Storage {
-	db, err := sql.Open("sqlite", dbFile)
+// NewStorage opens the database of dsn (see OpenDB) and applies pending schema migrations.
+func NewStorage(dsn string) *Storage {
+	bunDB, err := OpenDB(dsn)
 	if err != nil {
 		log.Fatal(err)
 	}
-	bunDB := bun.NewDB(db, sqlitedialect.New())
-	ctx := context.Background()
 
-	// Create tables with Bun (no migrations)
-	if _, err := bunDB.NewCreateTable().Model((*playerRow)(nil)).IfNotExists().Exec(ctx); err != nil {
-		log.Fatal(err)
-	}
-	if _, err := bunDB.NewCreateTable().Model((*playerStatsRow)(nil)).IfNotExists().Exec(ctx); err != nil {
-		log.Fatal(err)
-	}
-	if _, err := bunDB.NewCreateTable().Model((*matchRow)(nil)).IfNotExists().Exec(ctx); err != nil {
-		log.Fatal(err)
-	}
-	if _, err := bunDB.NewCreateTable().Model((*matchPlayerRow)(nil)).IfNotExists().Exec(ctx); err != nil {
+	group, err := Migrate(context.Background(), bunDB)
+	if err != nil {
 		log.Fatal(err)
 	}
-	if _, err := bunDB.NewCreateTable().Model((*throwRow)(nil)).IfNotExists().Exec(ctx); err != nil {
-		log.Fatal(err)
+	if !group.IsZero() {
+		log.Printf("applied schema migrations %s", group)
 	}
 
 	return &Storage{Bun: bunDB}
 }
 
+// RunInTx runs fn in a database transaction that is committed if fn returns nil and rolled back otherwise.
+// The Repository passed to fn is a Storage using the transaction; inside a transaction fn joins the current one.
+func (s *Storage) RunInTx(ctx context.Context, fn func(ctx context.Context, tx Repository) error) error {
+	return s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		return fn(ctx, tx)
+	})
+}
+
+func (s *Storage) runInTx(ctx context.Context, fn func(ctx context.Context, tx *Storage) error) error {
+	if s.tx != nil {
+		return fn(ctx, s)
+	}
+	return s.Bun.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(ctx, &Storage{Bun: s.Bun, tx: tx})
+	})
+}
+
+// db returns the transaction of the Storage or the database outside of one.
+func (s *Storage) db() bun.IDB {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.Bun
+}
+
 // ---------- PLAYER METHODS ----------
 
-// CreatePlayer inserts a player using Bun ORM
-func (s *Storage) CreatePlayer(name string) (*models.Player, error) {
-	ctx := context.Background()
-	p := &playerRow{ID: uuid.New().String(), Name: name}
-	if _, err := s.Bun.NewInsert().Model(p).Exec(ctx); err != nil {
+// CreatePlayer inserts a player together with its stats row
+func (s *Storage) CreatePlayer(ctx context.Context, name string) (*models.Player, error) {
+	p := &playerRow{ID: uuid.New().String(), Name: name, CreatedAt: time.Now()}
+	err := s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		if _, err := tx.db().NewInsert().Model(p).Exec(ctx); err != nil {
+			return err
+		}
+		ps := &playerStatsRow{Pid: p.ID, Rating: rating.Initial}
+		_, err := tx.db().NewInsert().Model(ps).On("CONFLICT (pid) DO NOTHING").Exec(ctx)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
-	// initialize stats row via Bun (insert ignore)
-	ps := &playerStatsRow{Pid: p.ID}
-	if _, err := s.Bun.NewInsert().Model(ps).On("CONFLICT (pid) DO NOTHING").Exec(ctx); err != nil {
-		// Not fatal: stats row will be created on-demand later.
-		log.Printf("warning: init player_stats for %s failed: %v", p.ID, err)
-	}
-	return &models.Player{ID: p.ID, Name: p.Name}, nil
+	return toPlayer(p), nil
 }
 
 // UpdatePlayer updates a player (Bun) and returns the updated record
-func (s *Storage) UpdatePlayer(id string, name string) (*models.Player, error) {
-	ctx := context.Background()
+func (s *Storage) UpdatePlayer(ctx context.Context, id string, name string) (*models.Player, error) {
 	// Update using Bun
-	_, err := s.Bun.NewUpdate().Table("players").Set("name = ?", name).Where("id = ?", id).Exec(ctx)
+	_, err := s.db().NewUpdate().Table("players").Set("name = ?", name).Where("id = ?", id).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetPlayer(id)
+	return s.GetPlayer(ctx, id)
 }
 
 // UpdatePlayerModel updates by model and returns the updated instance
-func (s *Storage) UpdatePlayerModel(p *models.Player) (*models.Player, error) {
+func (s *Storage) UpdatePlayerModel(ctx context.Context, p *models.Player) (*models.Player, error) {
 	if p == nil || p.ID == "" {
 		return nil, errors.New("invalid player model")
 	}
-	ctx := context.Background()
-	_, err := s.Bun.NewUpdate().Table("players").Set("name = ?", p.Name).Where("id = ?", p.ID).Exec(ctx)
+	_, err := s.db().NewUpdate().Table("players").Set("name = ?", p.Name).Where("id = ?", p.ID).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetPlayer(p.ID)
+	return s.GetPlayer(ctx, p.ID)
 }
 
 // GetPlayers returns all players.
-func (s *Storage) GetPlayers() ([]*models.Player, error) {
-	ctx := context.Background()
-	var list []models.Player
-	if err := s.Bun.NewSelect().Table("players").Column("id", "name").Scan(ctx, &list); err != nil {
+func (s *Storage) GetPlayers(ctx context.Context) ([]*models.Player, error) {
+	var list []playerRow
+	if err := s.db().NewSelect().Model(&list).Scan(ctx); err != nil {
 		return nil, err
 	}
 	players := make([]*models.Player, 0, len(list))
 	for i := range list {
-		p := list[i]
-		players = append(players, &p)
+		players = append(players, toPlayer(&list[i]))
 	}
 	return players, nil
 }
 
 // GetAllPlayers returns all players (alias for GetPlayers for completeness)
-func (s *Storage) GetAllPlayers() ([]*models.Player, error) {
-	return s.GetPlayers()
+func (s *Storage) GetAllPlayers(ctx context.Context) ([]*models.Player, error) {
+	return s.GetPlayers(ctx)
 }
 
 // GetPlayer returns a player by ID.
-func (s *Storage) GetPlayer(id string) (*models.Player, error) {
-	ctx := context.Background()
-	var p models.Player
-	if err := s.Bun.NewSelect().Table("players").Column("id", "name").Where("id = ?", id).Scan(ctx, &p); err != nil {
-		return nil, err
+func (s *Storage) GetPlayer(ctx context.Context, id string) (*models.Player, error) {
+	var p playerRow
+	if err := s.db().NewSelect().Model(&p).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, notFound(err)
 	}
-	return &p, nil
+	return toPlayer(&p), nil
 }
 
 // DeletePlayer removes a player and related data by player ID.
-func (s *Storage) DeletePlayer(id string) error {
-	ctx := context.Background()
-	if _, err := s.Bun.NewDelete().Table("match_player_throws").Where("pid = ?", id).Exec(ctx); err != nil {
-		// Non-fatal: continue cleanup
-		log.Printf("warning: cleanup match_player_throws for %s failed: %v", id, err)
-	}
-	if _, err := s.Bun.NewDelete().Table("match_players").Where("pid = ?", id).Exec(ctx); err != nil {
+func (s *Storage) DeletePlayer(ctx context.Context, id string) error {
+	return s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		if _, err := tx.db().NewDelete().Table("match_player_throws").Where("pid = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.db().NewDelete().Table("match_players").Where("pid = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.db().NewDelete().Table("player_stats").Where("pid = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.db().NewDelete().Table("rating_history").Where("pid = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.db().NewDelete().Table("players").Where("id = ?", id).Exec(ctx)
 		return err
-	}
-	if _, err := s.Bun.NewDelete().Table("player_stats").Where("pid = ?", id).Exec(ctx); err != nil {
-		// Non-fatal: continue
-		log.Printf("warning: cleanup player_stats for %s failed: %v", id, err)
-	}
-	_, err := s.Bun.NewDelete().Table("players").Where("id = ?", id).Exec(ctx)
-	return err
+	})
 }
 
 // CreateMatch creates a new match with the given players and settings.
-func (s *Storage) CreateMatch(players []string, startAt int, startMode, endMode uint8) (*models.Match, error) {
-	ctx := context.Background()
+// legs and sets are the legs needed to win a set and the sets needed to win the match.
+func (s *Storage) CreateMatch(ctx context.Context, players []string, gameType models.GameType, startAt int, startMode, endMode uint8, legs, sets int) (*models.Match, error) {
 	id := uuid.New().String()
-	mr := &matchRow{ID: id, IsActive: true, StartAt: startAt, Startmode: startMode, Endmode: endMode, CurrentPlayer: players[0], CurrentThrow: 0}
-	if _, err := s.Bun.NewInsert().Model(mr).Exec(ctx); err != nil {
-		return nil, err
-	}
-	for _, pid := range players {
-		mpr := &matchPlayerRow{Mid: id, Pid: pid, OverallThrows: 0, Score: startAt}
-		if _, err := s.Bun.NewInsert().Model(mpr).Exec(ctx); err != nil {
-			return nil, err
+	mr := &matchRow{ID: id, IsActive: true, GameType: uint8(gameType), StartAt: startAt, Startmode: startMode, Endmode: endMode, CurrentPlayer: players[0], CurrentThrow: 0, Legs: legs, Sets: sets, Version: 1, CreatedAt: time.Now()}
+	err := s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		if _, err := tx.db().NewInsert().Model(mr).Exec(ctx); err != nil {
+			return err
 		}
+		for seat, pid := range players {
+			mpr := &matchPlayerRow{Mid: id, Pid: pid, Seat: seat, OverallThrows: 0, Score: startAt, TurnStartScore: startAt}
+			if gameType.IsCricket() {
+				mpr.Marks = models.CricketMarks{}
+			}
+			if _, err := tx.db().NewInsert().Model(mpr).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	m := &models.Match{
-		ID:           id,
-		Players:      players,
-		CurrentThrow: 0,
-		StartAt:      startAt,
-		StartMode:    startMode,
-		EndMode:      endMode,
-		Scores:       make(map[string]int),
-	}
+	m := toMatch(mr)
 	for _, pid := range players {
+		m.Players = append(m.Players, pid)
 		m.Scores[pid] = startAt
+		m.LegsWon[pid] = 0
+		m.SetsWon[pid] = 0
+		if m.Marks != nil {
+			m.Marks[pid] = models.CricketMarks{}
+		}
 	}
 	return m, nil
 }
 
 // GetMatches returns all matches with their players and scores.
-func (s *Storage) GetMatches() ([]*models.Match, error) {
-	ctx := context.Background()
+func (s *Storage) GetMatches(ctx context.Context) ([]*models.Match, error) {
 	var mrows []matchRow
-	if err := s.Bun.NewSelect().Model(&mrows).Column("id", "startAt", "startmode", "endmode", "currentThrow", "currentPlayer").Scan(ctx); err != nil {
+	if err := s.db().NewSelect().Model(&mrows).Scan(ctx); err != nil {
 		return nil, err
 	}
 	res := make([]*models.Match, 0, len(mrows))
-	for _, mr := range mrows {
-		m := &models.Match{
-			ID:            mr.ID,
-			Players:       []string{},
-			CurrentThrow:  uint32(mr.CurrentThrow),
-			CurrentPlayer: mr.CurrentPlayer,
-			StartAt:       mr.StartAt,
-			StartMode:     mr.Startmode,
-			EndMode:       mr.Endmode,
-			Scores:        make(map[string]int),
-		}
-		var mps []matchPlayerRow
-		if err := s.Bun.NewSelect().Model(&mps).Column("pid", "score").Where("mid = ?", mr.ID).Scan(ctx); err != nil {
+	for i := range mrows {
+		m := toMatch(&mrows[i])
+		if err := s.loadMatchPlayers(ctx, m); err != nil {
 			return nil, err
 		}
-		for _, mp := range mps {
-			m.Players = append(m.Players, mp.Pid)
-			m.Scores[mp.Pid] = mp.Score
-		}
 		res = append(res, m)
 	}
 	return res, nil
 }
 
 // GetAllMatches returns all matches (alias for GetMatches)
-func (s *Storage) GetAllMatches() ([]*models.Match, error) {
-	return s.GetMatches()
+func (s *Storage) GetAllMatches(ctx context.Context) ([]*models.Match, error) {
+	return s.GetMatches(ctx)
 }
 
 // GetMatch returns a single match by id
-func (s *Storage) GetMatch(id string) (*models.Match, error) {
-	ctx := context.Background()
+func (s *Storage) GetMatch(ctx context.Context, id string) (*models.Match, error) {
 	var mr matchRow
-	if err := s.Bun.NewSelect().Model(&mr).Column("id", "startAt", "startmode", "endmode", "currentThrow", "currentPlayer").Where("id = ?", id).Scan(ctx); err != nil {
-		return nil, err
-	}
-	m := &models.Match{
-		ID:            mr.ID,
-		Players:       []string{},
-		CurrentThrow:  uint32(mr.CurrentThrow),
-		CurrentPlayer: mr.CurrentPlayer,
-		WonBy:         *mr.WonBy,
-		StartAt:       mr.StartAt,
-		StartMode:     mr.Startmode,
-		EndMode:       mr.Endmode,
-		Scores:        make(map[string]int),
+	if err := s.db().NewSelect().Model(&mr).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, notFound(err)
 	}
-	var mps []matchPlayerRow
-	if err := s.Bun.NewSelect().Model(&mps).Column("pid", "score").Where("mid = ?", mr.ID).Scan(ctx); err != nil {
+	m := toMatch(&mr)
+	if err := s.loadMatchPlayers(ctx, m); err != nil {
 		return nil, err
 	}
-	for _, mp := range mps {
-		m.Players = append(m.Players, mp.Pid)
-		m.Scores[mp.Pid] = mp.Score
-	}
 	return m, nil
 }
 
-// UpdateMatch persists the mutable fields of a match.
-func (s *Storage) UpdateMatch(match *models.Match) error {
-	ctx := context.Background()
-	_, err := s.Bun.NewUpdate().Table("matches").
-		Set("currentPlayer = ?", match.CurrentPlayer).
-		Set("currentThrow = ?", match.CurrentThrow).
-		Where("id = ?", match.ID).Exec(ctx)
-	return err
+// UpdateMatch persists the mutable fields of a match and bumps its version. It returns ErrVersionConflict
+// if the match was changed since it was loaded.
+func (s *Storage) UpdateMatch(ctx context.Context, match *models.Match) error {
+	res, err := s.db().NewUpdate().Table("matches").
+		Set(`"currentPlayer" = ?`, match.CurrentPlayer).
+		Set(`"currentThrow" = ?`, match.CurrentThrow).
+		Set("leg = ?", match.Leg).
+		Set("version = version + 1").
+		Where("id = ?", match.ID).
+		Where("version = ?", match.Version).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	match.Version++
+	return nil
 }
 
 // UpdateMatchModel updates a match and returns the updated instance
-func (s *Storage) UpdateMatchModel(m *models.Match) (*models.Match, error) {
+func (s *Storage) UpdateMatchModel(ctx context.Context, m *models.Match) (*models.Match, error) {
 	if m == nil || m.ID == "" {
 		return nil, errors.New("invalid match model")
 	}
-	ctx := context.Background()
-	_, err := s.Bun.NewUpdate().Table("matches").
-		Set("currentPlayer = ?", m.CurrentPlayer).
-		Set("currentThrow = ?", m.CurrentThrow).
-		Set("startAt = ?", m.StartAt).
+	_, err := s.db().NewUpdate().Table("matches").
+		Set(`"currentPlayer" = ?`, m.CurrentPlayer).
+		Set(`"currentThrow" = ?`, m.CurrentThrow).
+		Set(`"startAt" = ?`, m.StartAt).
 		Set("startmode = ?", m.StartMode).
 		Set("endmode = ?", m.EndMode).
 		Where("id = ?", m.ID).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetMatch(m.ID)
+	return s.GetMatch(ctx, m.ID)
 }
 
 // DeleteMatch removes a match and its associated rows by match ID.
-func (s *Storage) DeleteMatch(id string) error {
-	ctx := context.Background()
-	// remove throws for this match
-	if _, err := s.Bun.NewDelete().Table("match_player_throws").Where("mid = ?", id).Exec(ctx); err != nil {
-		log.Printf("warning: cleanup match_player_throws for mid %s failed: %v", id, err)
-	}
-	// remove match_players entries
-	if _, err := s.Bun.NewDelete().Table("match_players").Where("mid = ?", id).Exec(ctx); err != nil {
-		return err
-	}
-	// remove match
-	_, err := s.Bun.NewDelete().Table("matches").Where("id = ?", id).Exec(ctx)
-	return err
+func (s *Storage) DeleteMatch(ctx context.Context, id string) error {
+	return s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		pids, err := tx.matchPids(ctx, id)
+		if err != nil {
+			return err
+		}
+		rated, err := tx.isRated(ctx, id)
+		if err != nil {
+			return err
+		}
+		// remove throws for this match
+		if _, err := tx.db().NewDelete().Table("match_player_throws").Where("mid = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		// remove match_players entries
+		if _, err := tx.db().NewDelete().Table("match_players").Where("mid = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		// remove match
+		if _, err := tx.db().NewDelete().Table("matches").Where("id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if err := tx.refreshPlayerStats(ctx, pids...); err != nil {
+			return err
+		}
+		if !rated {
+			return nil
+		}
+		return tx.recomputeRatings(ctx)
+	})
 }
 
 // GetActiveMatch returns the active match by ID or an error if not active or not found.
-func (s *Storage) GetActiveMatch(mid string) (*models.Match, error) {
-	ctx := context.Background()
+func (s *Storage) GetActiveMatch(ctx context.Context, mid string) (*models.Match, error) {
 	var mr matchRow
-	if err := s.Bun.NewSelect().Model(&mr).
-		Column("id", "startAt", "startmode", "endmode", "currentThrow", "currentPlayer").
-		Where("id = ?", mid).Where("isActive = 1").Scan(ctx); err != nil {
+	if err := s.db().NewSelect().Model(&mr).
+		Where("id = ?", mid).Where(`"isActive" = ?`, true).Scan(ctx); err != nil {
+		return nil, notFound(err)
+	}
+	m := toMatch(&mr)
+	if err := s.loadMatchPlayers(ctx, m); err != nil {
 		return nil, err
 	}
+	return m, nil
+}
+
+// toMatch converts a match row into a match model without any players.
+func toMatch(mr *matchRow) *models.Match {
 	m := &models.Match{
 		ID:            mr.ID,
 		Players:       []string{},
+		GameType:      models.GameType(mr.GameType),
 		CurrentThrow:  uint32(mr.CurrentThrow),
 		CurrentPlayer: mr.CurrentPlayer,
 		StartAt:       mr.StartAt,
 		StartMode:     mr.Startmode,
 		EndMode:       mr.Endmode,
 		Scores:        make(map[string]int),
+		Legs:          max(mr.Legs, 1),
+		Sets:          max(mr.Sets, 1),
+		Leg:           mr.Leg,
+		LegsWon:       make(map[string]int),
+		SetsWon:       make(map[string]int),
+		Version:       mr.Version,
+		CreatedAt:     mr.CreatedAt,
+	}
+	if mr.WonBy != nil {
+		m.WonBy = *mr.WonBy
 	}
+	if !mr.StartedAt.IsZero() {
+		m.StartedAt = &mr.StartedAt
+	}
+	if !mr.FinishedAt.IsZero() {
+		m.FinishedAt = &mr.FinishedAt
+	}
+	if m.GameType.IsCricket() {
+		m.Marks = make(map[string]models.CricketMarks)
+	}
+	return m
+}
+
+// loadMatchPlayers fills the players, scores, cricket marks and legs/sets won of a match from match_players.
+func (s *Storage) loadMatchPlayers(ctx context.Context, m *models.Match) error {
 	var mps []matchPlayerRow
-	if err := s.Bun.NewSelect().Model(&mps).Column("pid", "score").Where("mid = ?", mr.ID).Scan(ctx); err != nil {
-		return nil, err
+	if err := s.db().NewSelect().Model(&mps).Column("pid", "score", "marks", "legsWon", "setsWon").Where("mid = ?", m.ID).Order("seat ASC").Scan(ctx); err != nil {
+		return err
 	}
 	for _, mp := range mps {
 		m.Players = append(m.Players, mp.Pid)
 		m.Scores[mp.Pid] = mp.Score
+		m.LegsWon[mp.Pid] = mp.LegsWon
+		m.SetsWon[mp.Pid] = mp.SetsWon
+		if m.Marks != nil {
+			m.Marks[mp.Pid] = marksOrEmpty(mp.Marks)
+		}
 	}
-	return m, nil
+	return nil
+}
+
+func marksOrEmpty(marks models.CricketMarks) models.CricketMarks {
+	if marks == nil {
+		return models.CricketMarks{}
+	}
+	return marks
 }
 
 // ---------- MATCH_PLAYER METHODS ----------
 // GetMatchPlayerModel returns the match-player row for a given match and player.
-func (s *Storage) GetMatchPlayerModel(mid, pid string) (*models.MatchPlayer, error) {
-	ctx := context.Background()
+func (s *Storage) GetMatchPlayerModel(ctx context.Context, mid, pid string) (*models.MatchPlayer, error) {
 	var mpr matchPlayerRow
-	if err := s.Bun.NewSelect().Model(&mpr).Where("mid = ?", mid).Where("pid = ?", pid).Scan(ctx); err != nil {
-		return nil, err
+	if err := s.db().NewSelect().Model(&mpr).Where("mid = ?", mid).Where("pid = ?", pid).Scan(ctx); err != nil {
+		return nil, notFound(err)
 	}
-	return &models.MatchPlayer{Mid: mpr.Mid, Pid: mpr.Pid, OverallThrows: mpr.OverallThrows, Score: mpr.Score}, nil
+	return toMatchPlayer(&mpr), nil
 }
 
-// WonMatch marks a match as finished and stores the winner.
-func (s *Storage) WonMatch(match *models.Match) error {
-	ctx := context.Background()
-	if _, err := s.Bun.NewUpdate().Table("matches").
-		Set("isActive = ?", false).
-		Set("wonBy = ?", match.CurrentPlayer).
-		Where("id = ?", match.ID).Exec(ctx); err != nil {
-		return err
-	}
+// ReopenMatch marks a finished match as active again and clears its winner.
+func (s *Storage) ReopenMatch(ctx context.Context, mid string) error {
+	return s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		if _, err := tx.db().NewUpdate().Table("matches").
+			Set(`"isActive" = ?`, true).
+			Set(`"wonBy" = NULL`).
+			Set(`"finishedAt" = NULL`).
+			Where("id = ?", mid).Exec(ctx); err != nil {
+			return err
+		}
+		if err := tx.refreshMatchPlayerStats(ctx, mid); err != nil {
+			return err
+		}
 
-	return nil
+		rated, err := tx.isRated(ctx, mid)
+		if err != nil || !rated {
+			return err
+		}
+		return tx.recomputeRatings(ctx)
+	})
+}
+
+// WonMatch marks a match as finished, stores the winner (WonBy, or the current player if unset)
+// and refreshes the player stats and ratings of everyone in the match. A match won again keeps its
+// FinishedAt.
+func (s *Storage) WonMatch(ctx context.Context, match *models.Match) error {
+	return s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		winner := match.WonBy
+		if winner == "" {
+			winner = match.CurrentPlayer
+		}
+		if _, err := tx.db().NewUpdate().Table("matches").
+			Set(`"isActive" = ?`, false).
+			Set(`"wonBy" = ?`, winner).
+			// a match won again after an edit keeps the time it was first finished
+			Set(`"finishedAt" = COALESCE("finishedAt", ?)`, time.Now()).
+			Where("id = ?", match.ID).Exec(ctx); err != nil {
+			return err
+		}
+		if err := tx.refreshMatchPlayerStats(ctx, match.ID); err != nil {
+			return err
+		}
+
+		return tx.updateRatings(ctx, match.ID, winner)
+	})
 }
 
-func (s *Storage) GetLastTurnHistory(match *models.Match) (*models.History, error) {
-	ctx := context.Background()
-	history := models.History{}
+// GetFinishedMatches returns the matches that have a winner and were finished at or after since.
+// A zero since returns every finished match.
+func (s *Storage) GetFinishedMatches(ctx context.Context, since time.Time) ([]*models.Match, error) {
+	var mrows []matchRow
+	q := s.db().NewSelect().Model(&mrows).Where(`"isActive" = ?`, false).Where(`"wonBy" IS NOT NULL`)
+	if !since.IsZero() {
+		q = q.Where(`"finishedAt" >= ?`, since)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	out := make([]*models.Match, 0, len(mrows))
+	for i := range mrows {
+		m := toMatch(&mrows[i])
+		if err := s.loadMatchPlayers(ctx, m); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func (s *Storage) GetLastTurnHistory(ctx context.Context, match *models.Match) (*models.History, error) {
+	history := models.History{History: make(map[string][]models.HistoryElement, len(match.Players))}
 
 	for _, pid := range match.Players {
 		var rows []throwRow
 
-		selectQuery := s.Bun.NewSelect().
+		selectQuery := s.db().NewSelect().
 			Model((*throwRow)(nil)).
 			ColumnExpr("MAX(turn)").
 			Where("mid = ?", match.ID).
-			Where("pid = ?", pid)
+			Where("pid = ?", pid).
+			Where("leg = ?", match.Leg)
 
-		err := s.Bun.NewSelect().
+		err := s.db().NewSelect().
 			Model(&rows).
 			Where("mid = ?", match.ID).
 			Where("pid = ?", pid).
+			Where("leg = ?", match.Leg).
 			Where("turn = (?)", selectQuery).
 			Order("id DESC").
 			Scan(ctx)
@@ -362,14 +485,13 @@ func (s *Storage) GetLastTurnHistory(match *models.Match) (*models.History, erro
 	return &history, nil
 }
 
-func (s *Storage) GetHistory(match *models.Match) (*models.History, error) {
-	ctx := context.Background()
-	history := models.History{}
+func (s *Storage) GetHistory(ctx context.Context, match *models.Match) (*models.History, error) {
+	history := models.History{History: make(map[string][]models.HistoryElement, len(match.Players))}
 
 	for _, pid := range match.Players {
 		var rows []throwRow
 
-		err := s.Bun.NewSelect().
+		err := s.db().NewSelect().
 			Model(&rows).
 			Where("mid = ?", match.ID).
 			Where("pid = ?", pid).
@@ -389,9 +511,13 @@ func addHistoryItem(rows []throwRow, history models.History, pid string) {
 	historyItemList := make([]models.HistoryElement, 0, len(rows))
 	for _, row := range rows {
 		historyItem := models.HistoryElement{
+			ID:         row.ID,
 			Throw:      models.ThrowType(row.ThrowType),
 			EndedTurn:  row.EndedTurn,
 			TurnNumber: row.Turn,
+			Bust:       row.Busted,
+			Leg:        row.Leg,
+			ThrownAt:   row.ThrownAt,
 		}
 
 		historyItemList = append(historyItemList, historyItem)
@@ -403,36 +529,72 @@ func addHistoryItem(rows []throwRow, history models.History, pid string) {
 // ---- Bun table models (internal) ----
 type playerRow struct {
 	bun.BaseModel `bun:"table:players"`
-	ID            string `bun:",pk"`
-	Name          string `bun:",notnull"`
+	ID            string    `bun:",pk"`
+	Name          string    `bun:",notnull"`
+	CreatedAt     time.Time `bun:"createdAt,nullzero"`
+}
+
+func toPlayer(r *playerRow) *models.Player {
+	return &models.Player{ID: r.ID, Name: r.Name, CreatedAt: r.CreatedAt}
 }
 
 type playerStatsRow struct {
 	bun.BaseModel `bun:"table:player_stats"`
-	Pid           string `bun:",pk"`
-	Matches       int    `bun:",notnull,default:0"`
-	Throws        int    `bun:",notnull,default:0"`
-	TotalScore    int    `bun:"totalScore,notnull,default:0"`
+	Pid           string  `bun:",pk"`
+	Matches       int     `bun:",notnull,default:0"`
+	Throws        int     `bun:",notnull,default:0"`
+	TotalScore    int     `bun:"totalScore,notnull,default:0"`
+	Wins          int     `bun:"wins,notnull,default:0"`
+	Rating        float64 `bun:"rating,notnull,default:1500"`
 }
 
 type matchRow struct {
 	bun.BaseModel `bun:"table:matches"`
 	ID            string  `bun:",pk"`
 	IsActive      bool    `bun:"isActive,notnull,default:true"`
+	GameType      uint8   `bun:"gameType,notnull,default:0"`
 	StartAt       int     `bun:"startAt,notnull"`
 	Startmode     uint8   `bun:"startmode,notnull"`
 	Endmode       uint8   `bun:"endmode,notnull"`
 	CurrentPlayer string  `bun:"currentPlayer,nullzero"`
 	CurrentThrow  int     `bun:"currentThrow,notnull,default:0"`
 	WonBy         *string `bun:"wonBy,nullzero"`
+	Legs          int     `bun:"legs,notnull,default:1"`
+	Sets          int     `bun:"sets,notnull,default:1"`
+	Leg           int     `bun:"leg,notnull,default:0"`
+	Version       int     `bun:"version,notnull,default:1"`
+	// The timestamps are zero for matches stored before they were recorded.
+	// StartedAt is set by the first throw, FinishedAt is zero while the match is active.
+	CreatedAt  time.Time `bun:"createdAt,nullzero"`
+	StartedAt  time.Time `bun:"startedAt,nullzero"`
+	FinishedAt time.Time `bun:"finishedAt,nullzero"`
 }
 
 type matchPlayerRow struct {
-	bun.BaseModel `bun:"table:match_players"`
-	Mid           string `bun:",pk"`
-	Pid           string `bun:",pk"`
-	OverallThrows int    `bun:"overallThrows,notnull,default:0"`
-	Score         int    `bun:",notnull,default:0"`
+	bun.BaseModel  `bun:"table:match_players"`
+	Mid            string              `bun:",pk"`
+	Pid            string              `bun:",pk"`
+	Seat           int                 `bun:"seat,notnull,default:0"`
+	OverallThrows  int                 `bun:"overallThrows,notnull,default:0"`
+	Score          int                 `bun:",notnull,default:0"`
+	TurnStartScore int                 `bun:"turnStartScore,notnull,default:0"`
+	Marks          models.CricketMarks `bun:"marks,type:json"`
+	LegsWon        int                 `bun:"legsWon,notnull,default:0"`
+	SetsWon        int                 `bun:"setsWon,notnull,default:0"`
+}
+
+func toMatchPlayer(r *matchPlayerRow) *models.MatchPlayer {
+	return &models.MatchPlayer{
+		Mid:            r.Mid,
+		Pid:            r.Pid,
+		Seat:           r.Seat,
+		OverallThrows:  r.OverallThrows,
+		Score:          r.Score,
+		TurnStartScore: r.TurnStartScore,
+		Marks:          r.Marks,
+		LegsWon:        r.LegsWon,
+		SetsWon:        r.SetsWon,
+	}
 }
 
 type throwRow struct {
@@ -442,5 +604,8 @@ type throwRow struct {
 	Pid           string
 	ThrowType     int
 	Turn          int
-	EndedTurn     bool `bun:"endedTurn,notnull,default:false"`
+	Leg           int       `bun:"leg,notnull,default:0"`
+	EndedTurn     bool      `bun:"endedTurn,notnull,default:false"`
+	Busted        bool      `bun:"busted,notnull,default:false"`
+	ThrownAt      time.Time `bun:"thrownAt,nullzero"`
 }
@@ -3,115 +3,235 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"darts-counter/models"
+	"darts-counter/rating"
+
+	"github.com/uptrace/bun"
 )
 
 // Additional CRUD coverage for remaining tables and convenience helpers
 
 // CreatePlayerStatsDefault
-func (s *Storage) CreatePlayerStatsDefault(pid string) (*models.PlayerStats, error) {
+func (s *Storage) CreatePlayerStatsDefault(ctx context.Context, pid string) (*models.PlayerStats, error) {
 	if pid == "" {
 		return nil, errors.New("empty pid")
 	}
-	ctx := context.Background()
-	ps := &playerStatsRow{Pid: pid}
-	_, err := s.Bun.NewInsert().Model(ps).On("CONFLICT (pid) DO NOTHING").Exec(ctx)
+	ps := &playerStatsRow{Pid: pid, Rating: rating.Initial}
+	_, err := s.db().NewInsert().Model(ps).On("CONFLICT (pid) DO NOTHING").Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetPlayerStats(pid)
+	return s.GetPlayerStats(ctx, pid)
 }
 
-func (s *Storage) GetPlayerStats(pid string) (*models.PlayerStats, error) {
-	ctx := context.Background()
+func (s *Storage) GetPlayerStats(ctx context.Context, pid string) (*models.PlayerStats, error) {
 	var pr playerStatsRow
-	if err := s.Bun.NewSelect().Model(&pr).Where("pid = ?", pid).Scan(ctx); err != nil {
-		return nil, err
+	if err := s.db().NewSelect().Model(&pr).Where("pid = ?", pid).Scan(ctx); err != nil {
+		return nil, notFound(err)
 	}
-	return &models.PlayerStats{Pid: pr.Pid, Matches: pr.Matches, Throws: pr.Throws, TotalScore: pr.TotalScore}, nil
+	return toPlayerStats(&pr), nil
 }
 
 // GetAllPlayerStats
-func (s *Storage) GetAllPlayerStats() ([]*models.PlayerStats, error) {
-	ctx := context.Background()
+func (s *Storage) GetAllPlayerStats(ctx context.Context) ([]*models.PlayerStats, error) {
 	var rows []playerStatsRow
-	if err := s.Bun.NewSelect().Model(&rows).Scan(ctx); err != nil {
+	if err := s.db().NewSelect().Model(&rows).Scan(ctx); err != nil {
 		return nil, err
 	}
 	out := make([]*models.PlayerStats, 0, len(rows))
-	for _, r := range rows {
-		out = append(out, &models.PlayerStats{Pid: r.Pid, Matches: r.Matches, Throws: r.Throws, TotalScore: r.TotalScore})
+	for i := range rows {
+		out = append(out, toPlayerStats(&rows[i]))
 	}
 	return out, nil
 }
 
-func (s *Storage) UpdatePlayerStats(ps *models.PlayerStats) (*models.PlayerStats, error) {
+func (s *Storage) UpdatePlayerStats(ctx context.Context, ps *models.PlayerStats) (*models.PlayerStats, error) {
 	if ps == nil || ps.Pid == "" {
 		return nil, errors.New("invalid player stats model")
 	}
-	ctx := context.Background()
-	_, err := s.Bun.NewUpdate().TableExpr("player_stats").
+	_, err := s.db().NewUpdate().TableExpr("player_stats").
 		Set("matches = ?", ps.Matches).
 		Set("throws = ?", ps.Throws).
-		Set("totalScore = ?", ps.TotalScore).
+		Set(`"totalScore" = ?`, ps.TotalScore).
+		Set("wins = ?", ps.Wins).
 		Where("pid = ?", ps.Pid).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetPlayerStats(ps.Pid)
+	return s.GetPlayerStats(ctx, ps.Pid)
+}
+
+// refreshMatchPlayerStats recomputes the player stats of everyone taking part in a match.
+func (s *Storage) refreshMatchPlayerStats(ctx context.Context, mid string) error {
+	pids, err := s.matchPids(ctx, mid)
+	if err != nil {
+		return err
+	}
+	return s.refreshPlayerStats(ctx, pids...)
+}
+
+func (s *Storage) matchPids(ctx context.Context, mid string) ([]string, error) {
+	var pids []string
+	if err := s.db().NewSelect().Model((*matchPlayerRow)(nil)).Column("pid").Where("mid = ?", mid).Scan(ctx, &pids); err != nil {
+		return nil, err
+	}
+	return pids, nil
+}
+
+// refreshPlayerStats recomputes player_stats from the finished matches of each player:
+// matches played and won, darts thrown and the points scored in X01 (busted darts score nothing).
+func (s *Storage) refreshPlayerStats(ctx context.Context, pids ...string) error {
+	for _, pid := range pids {
+		finished := s.db().NewSelect().Model((*matchRow)(nil)).Column("id").Where(`"isActive" = ?`, false)
+
+		matches, err := s.db().NewSelect().Model((*matchPlayerRow)(nil)).
+			Where("pid = ?", pid).Where("mid IN (?)", finished).Count(ctx)
+		if err != nil {
+			return err
+		}
+		wins, err := s.db().NewSelect().Model((*matchRow)(nil)).Where(`"wonBy" = ?`, pid).Count(ctx)
+		if err != nil {
+			return err
+		}
+
+		var throws []throwRow
+		if err := s.db().NewSelect().Model(&throws).
+			Column("throw_type", "busted", "mid").
+			Where("pid = ?", pid).Where("mid IN (?)", finished).Scan(ctx); err != nil {
+			return err
+		}
+		x01, err := s.x01MatchIDs(ctx, throws)
+		if err != nil {
+			return err
+		}
+		totalScore := 0
+		for _, t := range throws {
+			if x01[t.Mid] && !t.Busted {
+				totalScore += models.ThrowType(t.ThrowType).ToPoints()
+			}
+		}
+
+		ps := &playerStatsRow{Pid: pid, Matches: matches, Wins: wins, Throws: len(throws), TotalScore: totalScore, Rating: rating.Initial}
+		if _, err := s.db().NewInsert().Model(ps).
+			On("CONFLICT (pid) DO UPDATE").
+			Set("matches = EXCLUDED.matches").
+			Set("wins = EXCLUDED.wins").
+			Set("throws = EXCLUDED.throws").
+			Set(`"totalScore" = EXCLUDED."totalScore"`).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// x01MatchIDs returns the set of X01 matches among the matches of throws.
+func (s *Storage) x01MatchIDs(ctx context.Context, throws []throwRow) (map[string]bool, error) {
+	x01 := make(map[string]bool)
+	if len(throws) == 0 {
+		return x01, nil
+	}
+	mids := make([]string, 0, len(throws))
+	for _, t := range throws {
+		mids = append(mids, t.Mid)
+	}
+	var ids []string
+	if err := s.db().NewSelect().Model((*matchRow)(nil)).Column("id").
+		Where("id IN (?)", bun.In(mids)).Where(`"gameType" = ?`, models.X01).Scan(ctx, &ids); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		x01[id] = true
+	}
+	return x01, nil
+}
+
+// GetPlayerMatches returns every match the player takes part in.
+func (s *Storage) GetPlayerMatches(ctx context.Context, pid string) ([]*models.Match, error) {
+	return s.GetSharedMatches(ctx, pid)
+}
+
+// GetSharedMatches returns every match all of the given players take part in.
+func (s *Storage) GetSharedMatches(ctx context.Context, pids ...string) ([]*models.Match, error) {
+	var mrows []matchRow
+	q := s.db().NewSelect().Model(&mrows)
+	for _, pid := range pids {
+		q = q.Where("id IN (?)", s.db().NewSelect().Model((*matchPlayerRow)(nil)).Column("mid").Where("pid = ?", pid))
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	out := make([]*models.Match, 0, len(mrows))
+	for i := range mrows {
+		m := toMatch(&mrows[i])
+		if err := s.loadMatchPlayers(ctx, m); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func toPlayerStats(r *playerStatsRow) *models.PlayerStats {
+	return &models.PlayerStats{Pid: r.Pid, Matches: r.Matches, Wins: r.Wins, Throws: r.Throws, TotalScore: r.TotalScore, Rating: r.Rating}
 }
 
-func (s *Storage) DeletePlayerStats(pid string) error {
-	ctx := context.Background()
-	_, err := s.Bun.NewDelete().TableExpr("player_stats").Where("pid = ?", pid).Exec(ctx)
+func (s *Storage) DeletePlayerStats(ctx context.Context, pid string) error {
+	_, err := s.db().NewDelete().TableExpr("player_stats").Where("pid = ?", pid).Exec(ctx)
 	return err
 }
 
 // CreateMatchPlayer
-func (s *Storage) CreateMatchPlayer(mid, pid string, startAt int) (*models.MatchPlayer, error) {
+func (s *Storage) CreateMatchPlayer(ctx context.Context, mid, pid string, startAt int) (*models.MatchPlayer, error) {
 	if mid == "" || pid == "" {
 		return nil, errors.New("empty ids")
 	}
-	ctx := context.Background()
-	mpr := &matchPlayerRow{Mid: mid, Pid: pid, OverallThrows: 0, Score: startAt}
-	if _, err := s.Bun.NewInsert().Model(mpr).Exec(ctx); err != nil {
+	// new players take the seat after everyone already in the match
+	seat, err := s.db().NewSelect().Model((*matchPlayerRow)(nil)).Where("mid = ?", mid).Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mpr := &matchPlayerRow{Mid: mid, Pid: pid, Seat: seat, OverallThrows: 0, Score: startAt, TurnStartScore: startAt}
+	if _, err := s.db().NewInsert().Model(mpr).Exec(ctx); err != nil {
 		return nil, err
 	}
-	return s.GetMatchPlayerModel(mid, pid)
+	return s.GetMatchPlayerModel(ctx, mid, pid)
 }
 
-func (s *Storage) GetAllMatchPlayers(mid string) ([]*models.MatchPlayer, error) {
-	ctx := context.Background()
+func (s *Storage) GetAllMatchPlayers(ctx context.Context, mid string) ([]*models.MatchPlayer, error) {
 	var rows []matchPlayerRow
-	if err := s.Bun.NewSelect().Model(&rows).Where("mid = ?", mid).Scan(ctx); err != nil {
+	if err := s.db().NewSelect().Model(&rows).Where("mid = ?", mid).Order("seat ASC").Scan(ctx); err != nil {
 		return nil, err
 	}
 	out := make([]*models.MatchPlayer, 0, len(rows))
-	for _, r := range rows {
-		out = append(out, &models.MatchPlayer{Mid: r.Mid, Pid: r.Pid, OverallThrows: r.OverallThrows, Score: r.Score})
+	for i := range rows {
+		out = append(out, toMatchPlayer(&rows[i]))
 	}
 	return out, nil
 }
 
-func (s *Storage) UpdateMatchPlayer(mp *models.MatchPlayer) (*models.MatchPlayer, error) {
+func (s *Storage) UpdateMatchPlayer(ctx context.Context, mp *models.MatchPlayer) (*models.MatchPlayer, error) {
 	if mp == nil || mp.Mid == "" || mp.Pid == "" {
 		return nil, errors.New("invalid match player model")
 	}
-	ctx := context.Background()
-	_, err := s.Bun.NewUpdate().TableExpr("match_players").
-		Set("overallThrows = ?", mp.OverallThrows).
+	_, err := s.db().NewUpdate().TableExpr("match_players").
+		Set(`"overallThrows" = ?`, mp.OverallThrows).
 		Set("score = ?", mp.Score).
+		Set(`"turnStartScore" = ?`, mp.TurnStartScore).
+		Set("marks = ?", mp.Marks).
+		Set(`"legsWon" = ?`, mp.LegsWon).
+		Set(`"setsWon" = ?`, mp.SetsWon).
 		Where("mid = ?", mp.Mid).Where("pid = ?", mp.Pid).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetMatchPlayerModel(mp.Mid, mp.Pid)
+	return s.GetMatchPlayerModel(ctx, mp.Mid, mp.Pid)
 }
 
-func (s *Storage) DeleteMatchPlayer(mid, pid string) error {
-	ctx := context.Background()
-	_, err := s.Bun.NewDelete().TableExpr("match_players").Where("mid = ?", mid).Where("pid = ?", pid).Exec(ctx)
+func (s *Storage) DeleteMatchPlayer(ctx context.Context, mid, pid string) error {
+	_, err := s.db().NewDelete().TableExpr("match_players").Where("mid = ?", mid).Where("pid = ?", pid).Exec(ctx)
 	return err
 }
 
@@ -122,66 +242,119 @@ type ThrowRecord struct {
 	Pid       string
 	ThrowType int // use models.ThrowType values
 	EndedTurn bool
+	Busted    bool
 	Turn      int
+	Leg       int
+	ThrownAt  time.Time
 }
 
-func (s *Storage) CreateThrow(tr ThrowRecord) (*ThrowRecord, error) {
+func (s *Storage) CreateThrow(ctx context.Context, tr ThrowRecord) (*ThrowRecord, error) {
 	if tr.Mid == "" || tr.Pid == "" {
 		return nil, errors.New("empty ids")
 	}
-	ctx := context.Background()
 	count, err := s.countEndedTurns(ctx, tr.Mid, tr.Pid)
 	if err != nil {
 		return nil, err
 	}
 	tr.Turn = 1 + count
 
-	row := &throwRow{Mid: tr.Mid, Pid: tr.Pid, ThrowType: tr.ThrowType, EndedTurn: tr.EndedTurn, Turn: tr.Turn}
-	if err := s.Bun.NewInsert().Model(row).Returning("*").Scan(ctx); err != nil {
+	if tr.ThrownAt.IsZero() {
+		tr.ThrownAt = time.Now()
+	}
+
+	row := &throwRow{Mid: tr.Mid, Pid: tr.Pid, ThrowType: tr.ThrowType, EndedTurn: tr.EndedTurn, Busted: tr.Busted, Turn: tr.Turn, Leg: tr.Leg, ThrownAt: tr.ThrownAt}
+	err = s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		if err := tx.db().NewInsert().Model(row).Returning("*").Scan(ctx); err != nil {
+			return err
+		}
+		// the first throw starts the match
+		_, err := tx.db().NewUpdate().Table("matches").
+			Set(`"startedAt" = ?`, tr.ThrownAt).
+			Where("id = ?", tr.Mid).
+			Where(`"startedAt" IS NULL`).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
-	return &ThrowRecord{ID: row.ID, Mid: row.Mid, Pid: row.Pid, ThrowType: row.ThrowType, EndedTurn: row.EndedTurn, Turn: row.Turn}, nil
+	return toThrowRecord(row), nil
 }
 
 func (s *Storage) countEndedTurns(ctx context.Context, mid, pid string) (int, error) {
-	return s.Bun.
+	return s.db().
 		NewSelect().
 		Model((*throwRow)(nil)). // no allocation; just use the table
 		Where("mid = ?", mid).
 		Where("pid = ?", pid).
-		Where("endedTurn = ?", true). // or .Where("endedTurn = 1") for SQLite
+		Where(`"endedTurn" = ?`, true).
 		Count(ctx)
 }
 
-func (s *Storage) GetThrow(id int64) (*ThrowRecord, error) {
-	ctx := context.Background()
-	var r throwRow
-	if err := s.Bun.NewSelect().Model(&r).Where("id = ?", id).Scan(ctx); err != nil {
+// GetThrows returns all throws of a match in the order they were thrown.
+func (s *Storage) GetThrows(ctx context.Context, mid string) ([]*ThrowRecord, error) {
+	var rows []throwRow
+	if err := s.db().NewSelect().Model(&rows).Where("mid = ?", mid).Order("id ASC").Scan(ctx); err != nil {
 		return nil, err
 	}
-	return &ThrowRecord{ID: r.ID, Mid: r.Mid, Pid: r.Pid, ThrowType: r.ThrowType, EndedTurn: r.EndedTurn, Turn: r.Turn}, nil
+	out := make([]*ThrowRecord, 0, len(rows))
+	for i := range rows {
+		out = append(out, toThrowRecord(&rows[i]))
+	}
+	return out, nil
 }
 
-func (s *Storage) UpdateThrow(tr *ThrowRecord) (*ThrowRecord, error) {
+// GetLastThrow returns the latest throw of a match.
+func (s *Storage) GetLastThrow(ctx context.Context, mid string) (*ThrowRecord, error) {
+	var r throwRow
+	if err := s.db().NewSelect().Model(&r).Where("mid = ?", mid).Order("id DESC").Limit(1).Scan(ctx); err != nil {
+		return nil, notFound(err)
+	}
+	return toThrowRecord(&r), nil
+}
+
+func (s *Storage) GetThrow(ctx context.Context, id int64) (*ThrowRecord, error) {
+	var r throwRow
+	if err := s.db().NewSelect().Model(&r).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, notFound(err)
+	}
+	return toThrowRecord(&r), nil
+}
+
+func (s *Storage) UpdateThrow(ctx context.Context, tr *ThrowRecord) (*ThrowRecord, error) {
 	if tr == nil || tr.ID == 0 {
 		return nil, errors.New("invalid throw model")
 	}
-	ctx := context.Background()
-	_, err := s.Bun.NewUpdate().TableExpr("match_player_throws").
+	_, err := s.db().NewUpdate().TableExpr("match_player_throws").
 		Set("mid = ?", tr.Mid).
 		Set("pid = ?", tr.Pid).
-		Set("throwType = ?", tr.ThrowType).
-		Set("endedTurn = ?", tr.EndedTurn).
+		Set("throw_type = ?", tr.ThrowType).
+		Set(`"endedTurn" = ?`, tr.EndedTurn).
+		Set("busted = ?", tr.Busted).
 		Set("turn = ?", tr.Turn).
+		Set("leg = ?", tr.Leg).
 		Where("id = ?", tr.ID).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return s.GetThrow(tr.ID)
+	return s.GetThrow(ctx, tr.ID)
 }
 
-func (s *Storage) DeleteThrow(id int64) error {
-	ctx := context.Background()
-	_, err := s.Bun.NewDelete().TableExpr("match_player_throws").Where("id = ?", id).Exec(ctx)
+func (s *Storage) DeleteThrow(ctx context.Context, id int64) error {
+	_, err := s.db().NewDelete().TableExpr("match_player_throws").Where("id = ?", id).Exec(ctx)
 	return err
 }
+
+// BustTurn marks every throw of a player's turn as busted.
+func (s *Storage) BustTurn(ctx context.Context, mid, pid string, turn int) error {
+	_, err := s.db().NewUpdate().TableExpr("match_player_throws").
+		Set("busted = ?", true).
+		Where("mid = ?", mid).
+		Where("pid = ?", pid).
+		Where("turn = ?", turn).Exec(ctx)
+	return err
+}
+
+func toThrowRecord(r *throwRow) *ThrowRecord {
+	return &ThrowRecord{ID: r.ID, Mid: r.Mid, Pid: r.Pid, ThrowType: r.ThrowType, EndedTurn: r.EndedTurn, Busted: r.Busted, Turn: r.Turn, Leg: r.Leg, ThrownAt: r.ThrownAt}
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+
+	"darts-counter/models"
+	"darts-counter/rating"
+
+	"github.com/uptrace/bun"
+)
+
+// GetRatingHistory returns the rating of a player after each rated match in the order they were rated.
+func (s *Storage) GetRatingHistory(ctx context.Context, pid string) ([]models.RatingPoint, error) {
+	var rows []ratingRow
+	if err := s.db().NewSelect().Model(&rows).Where("pid = ?", pid).Order("id ASC").Scan(ctx); err != nil {
+		return nil, err
+	}
+	out := make([]models.RatingPoint, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, models.RatingPoint{Mid: r.Mid, Rating: r.Rating, Delta: r.Delta})
+	}
+	return out, nil
+}
+
+// RecomputeRatings resets every rating and rates all finished matches again in the order they were finished.
+func (s *Storage) RecomputeRatings(ctx context.Context) error {
+	return s.runInTx(ctx, func(ctx context.Context, tx *Storage) error {
+		return tx.recomputeRatings(ctx)
+	})
+}
+
+func (s *Storage) recomputeRatings(ctx context.Context) error {
+	if _, err := s.db().NewDelete().Model((*ratingRow)(nil)).Where("1 = 1").Exec(ctx); err != nil {
+		return err
+	}
+	if _, err := s.db().NewUpdate().Model((*playerStatsRow)(nil)).Set("rating = ?", rating.Initial).Where("1 = 1").Exec(ctx); err != nil {
+		return err
+	}
+
+	// a finished match has no throws after its winning one, so the last throw orders matches by finish;
+	// matches without throws come first on every database
+	var mrows []matchRow
+	if err := s.db().NewSelect().Model(&mrows).
+		Column("id", "wonBy").
+		Where(`"isActive" = ?`, false).
+		Where(`"wonBy" IS NOT NULL`).
+		OrderExpr("COALESCE((SELECT MAX(t.id) FROM match_player_throws AS t WHERE t.mid = ?TableAlias.id), 0) ASC").
+		Scan(ctx); err != nil {
+		return err
+	}
+	for _, mr := range mrows {
+		if err := s.rateMatch(ctx, mr.ID, *mr.WonBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRatings rates a match that was just won. Matches rated before are re-rated from scratch
+// since every later rating depends on them.
+func (s *Storage) updateRatings(ctx context.Context, mid, winner string) error {
+	rated, err := s.isRated(ctx, mid)
+	if err != nil {
+		return err
+	}
+	if rated {
+		return s.recomputeRatings(ctx)
+	}
+	return s.rateMatch(ctx, mid, winner)
+}
+
+func (s *Storage) isRated(ctx context.Context, mid string) (bool, error) {
+	return s.db().NewSelect().Model((*ratingRow)(nil)).Where("mid = ?", mid).Exists(ctx)
+}
+
+// rateMatch applies the rating changes of a match won by winner to everyone in it and records them.
+func (s *Storage) rateMatch(ctx context.Context, mid, winner string) error {
+	pids, err := s.matchPids(ctx, mid)
+	if err != nil {
+		return err
+	}
+
+	var rows []playerStatsRow
+	if err := s.db().NewSelect().Model(&rows).Column("pid", "rating").Where("pid IN (?)", bun.In(pids)).Scan(ctx); err != nil {
+		return err
+	}
+	before := make(map[string]float64, len(pids))
+	for _, pid := range pids {
+		before[pid] = rating.Initial
+	}
+	for _, r := range rows {
+		before[r.Pid] = r.Rating
+	}
+
+	after := rating.Match(before, winner)
+	for _, pid := range pids {
+		ps := &playerStatsRow{Pid: pid, Rating: after[pid]}
+		if _, err := s.db().NewInsert().Model(ps).
+			On("CONFLICT (pid) DO UPDATE").
+			Set("rating = EXCLUDED.rating").
+			Exec(ctx); err != nil {
+			return err
+		}
+		rr := &ratingRow{Pid: pid, Mid: mid, Rating: after[pid], Delta: after[pid] - before[pid]}
+		if _, err := s.db().NewInsert().Model(rr).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ratingRow struct {
+	bun.BaseModel `bun:"table:rating_history"`
+	ID            int64 `bun:",pk,autoincrement"`
+	Pid           string
+	Mid           string
+	Rating        float64 `bun:",notnull"`
+	Delta         float64 `bun:",notnull"`
+}
@@ -0,0 +1,649 @@
+// Package storagetest contains the conformance tests every storage.Repository has to pass.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"darts-counter/models"
+	"darts-counter/rating"
+	"darts-counter/storage"
+)
+
+// Run runs the conformance tests against repositories returned by open, which is called once per test
+// and has to return an empty repository.
+func Run(t *testing.T, open func(t *testing.T) storage.Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, ctx context.Context, repo storage.Repository)
+	}{
+		{"Players", testPlayers},
+		{"Matches", testMatches},
+		{"VersionConflict", testVersionConflict},
+		{"MatchPlayers", testMatchPlayers},
+		{"Throws", testThrows},
+		{"History", testHistory},
+		{"WonAndReopened", testWonAndReopened},
+		{"FinishedAtKeptOnEdit", testFinishedAtKeptOnEdit},
+		{"DeleteMatch", testDeleteMatch},
+		{"SharedMatches", testSharedMatches},
+		{"RunInTx", testRunInTx},
+		{"RollbackInPlaceChanges", testRollbackInPlaceChanges},
+		{"NotFound", testNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, t.Context(), open(t))
+		})
+	}
+}
+
+func createPlayers(t *testing.T, ctx context.Context, repo storage.Repository, names ...string) []string {
+	t.Helper()
+	pids := make([]string, 0, len(names))
+	for _, name := range names {
+		p, err := repo.CreatePlayer(ctx, name)
+		if err != nil {
+			t.Fatalf("create player: %v", err)
+		}
+		pids = append(pids, p.ID)
+	}
+	return pids
+}
+
+func createMatch(t *testing.T, ctx context.Context, repo storage.Repository, gameType models.GameType, pids ...string) *models.Match {
+	t.Helper()
+	match, err := repo.CreateMatch(ctx, pids, gameType, 301, 0, 0, 1, 1)
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	return match
+}
+
+func createThrow(t *testing.T, ctx context.Context, repo storage.Repository, tr storage.ThrowRecord) *storage.ThrowRecord {
+	t.Helper()
+	created, err := repo.CreateThrow(ctx, tr)
+	if err != nil {
+		t.Fatalf("create throw: %v", err)
+	}
+	return created
+}
+
+// winMatch records a throw of winner and finishes the match.
+func winMatch(t *testing.T, ctx context.Context, repo storage.Repository, match *models.Match, winner string) {
+	t.Helper()
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: winner, ThrowType: int(models.D20), EndedTurn: true})
+	match.WonBy = winner
+	if err := repo.WonMatch(ctx, match); err != nil {
+		t.Fatalf("won match: %v", err)
+	}
+}
+
+func testPlayers(t *testing.T, ctx context.Context, repo storage.Repository) {
+	alice, err := repo.CreatePlayer(ctx, "Alice")
+	if err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	if alice.ID == "" || alice.Name != "Alice" || alice.CreatedAt.IsZero() {
+		t.Errorf("unexpected player %+v", alice)
+	}
+	bob := createPlayers(t, ctx, repo, "Bob")[0]
+
+	renamed, err := repo.UpdatePlayer(ctx, alice.ID, "Alicia")
+	if err != nil {
+		t.Fatalf("update player: %v", err)
+	}
+	if renamed.Name != "Alicia" {
+		t.Errorf("expected the new name, got %q", renamed.Name)
+	}
+	got, err := repo.GetPlayer(ctx, alice.ID)
+	if err != nil || got.Name != "Alicia" {
+		t.Errorf("expected the renamed player, got %+v, %v", got, err)
+	}
+
+	players, err := repo.GetPlayers(ctx)
+	if err != nil {
+		t.Fatalf("get players: %v", err)
+	}
+	if len(players) != 2 {
+		t.Fatalf("expected 2 players, got %d", len(players))
+	}
+
+	stats, err := repo.GetPlayerStats(ctx, bob)
+	if err != nil {
+		t.Fatalf("get player stats: %v", err)
+	}
+	if stats.Matches != 0 || stats.Rating != rating.Initial {
+		t.Errorf("expected empty stats with the initial rating, got %+v", stats)
+	}
+
+	if err := repo.DeletePlayer(ctx, bob); err != nil {
+		t.Fatalf("delete player: %v", err)
+	}
+	if _, err := repo.GetPlayer(ctx, bob); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the player to be deleted, got %v", err)
+	}
+	if _, err := repo.GetPlayerStats(ctx, bob); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the player stats to be deleted, got %v", err)
+	}
+	all, err := repo.GetAllPlayerStats(ctx)
+	if err != nil || len(all) != 1 {
+		t.Errorf("expected the stats of one player, got %d, %v", len(all), err)
+	}
+}
+
+func testMatches(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "b", "a")
+	match, err := repo.CreateMatch(ctx, pids, models.X01, 501, 1, 2, 3, 2)
+	if err != nil {
+		t.Fatalf("create match: %v", err)
+	}
+	if match.Version != 1 || match.CurrentPlayer != pids[0] || match.CreatedAt.IsZero() || match.StartedAt != nil {
+		t.Errorf("unexpected new match %+v", match)
+	}
+
+	got, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if len(got.Players) != 2 || got.Players[0] != pids[0] || got.Players[1] != pids[1] {
+		t.Errorf("expected the players in seat order %v, got %v", pids, got.Players)
+	}
+	if got.StartAt != 501 || got.StartMode != 1 || got.EndMode != 2 || got.Legs != 3 || got.Sets != 2 {
+		t.Errorf("unexpected settings %+v", got)
+	}
+	if got.Scores[pids[1]] != 501 || got.Marks != nil {
+		t.Errorf("expected start scores and no marks, got %v, %v", got.Scores, got.Marks)
+	}
+
+	cricket := createMatch(t, ctx, repo, models.Cricket, pids...)
+	got, err = repo.GetMatch(ctx, cricket.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if got.Marks == nil || got.Marks[pids[0]] == nil {
+		t.Errorf("expected empty marks for every player, got %v", got.Marks)
+	}
+
+	match.CurrentPlayer = pids[1]
+	match.CurrentThrow = 2
+	match.Leg = 1
+	if err := repo.UpdateMatch(ctx, match); err != nil {
+		t.Fatalf("update match: %v", err)
+	}
+	if match.Version != 2 {
+		t.Errorf("expected the version to be bumped, got %d", match.Version)
+	}
+	got, err = repo.GetActiveMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get active match: %v", err)
+	}
+	if got.CurrentPlayer != pids[1] || got.CurrentThrow != 2 || got.Leg != 1 || got.Version != 2 {
+		t.Errorf("expected the update to be stored, got %+v", got)
+	}
+
+	matches, err := repo.GetMatches(ctx)
+	if err != nil || len(matches) != 2 {
+		t.Errorf("expected 2 matches, got %d, %v", len(matches), err)
+	}
+}
+
+func testVersionConflict(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+	stale, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+
+	if err := repo.UpdateMatch(ctx, match); err != nil {
+		t.Fatalf("update match: %v", err)
+	}
+	stale.CurrentThrow = 1
+	if err := repo.UpdateMatch(ctx, stale); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected a version conflict, got %v", err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("expected a failed update to keep the version, got %d", stale.Version)
+	}
+	got, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if got.CurrentThrow != 0 || got.Version != 2 {
+		t.Errorf("expected the stale update to be discarded, got %+v", got)
+	}
+}
+
+func testMatchPlayers(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	match := createMatch(t, ctx, repo, models.Cricket, pids...)
+
+	mp, err := repo.GetMatchPlayerModel(ctx, match.ID, pids[1])
+	if err != nil {
+		t.Fatalf("get match player: %v", err)
+	}
+	mp.Score = 40
+	mp.TurnStartScore = 20
+	mp.OverallThrows = 3
+	mp.LegsWon = 1
+	mp.Marks = models.CricketMarks{20: 3}
+	if _, err := repo.UpdateMatchPlayer(ctx, mp); err != nil {
+		t.Fatalf("update match player: %v", err)
+	}
+	mp.Marks[19] = 1
+
+	mps, err := repo.GetAllMatchPlayers(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match players: %v", err)
+	}
+	if len(mps) != 2 || mps[0].Pid != pids[0] || mps[1].Pid != pids[1] {
+		t.Fatalf("expected the match players in seat order, got %+v", mps)
+	}
+	got := mps[1]
+	if got.Score != 40 || got.TurnStartScore != 20 || got.OverallThrows != 3 || got.LegsWon != 1 {
+		t.Errorf("expected the update to be stored, got %+v", got)
+	}
+	if len(got.Marks) != 1 || got.Marks[20] != 3 {
+		t.Errorf("expected only the stored marks, got %v", got.Marks)
+	}
+
+	m, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if m.Scores[pids[1]] != 40 || m.LegsWon[pids[1]] != 1 || m.Marks[pids[1]][20] != 3 {
+		t.Errorf("expected the match to reflect the match player, got %+v", m)
+	}
+}
+
+func testThrows(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	a, b := pids[0], pids[1]
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+
+	first := createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: a, ThrowType: int(models.S20)})
+	if first.ID == 0 || first.Turn != 1 || first.ThrownAt.IsZero() {
+		t.Errorf("unexpected first throw %+v", first)
+	}
+	m, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if m.StartedAt == nil || !m.StartedAt.Equal(first.ThrownAt) {
+		t.Errorf("expected the first throw to start the match, got %v", m.StartedAt)
+	}
+
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: a, ThrowType: int(models.S20), EndedTurn: true})
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: b, ThrowType: int(models.T20), EndedTurn: true})
+	third := createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: a, ThrowType: int(models.T20), ThrownAt: first.ThrownAt.Add(time.Minute)})
+	if third.Turn != 2 {
+		t.Errorf("expected a's second turn, got %d", third.Turn)
+	}
+	m, err = repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if !m.StartedAt.Equal(first.ThrownAt) {
+		t.Errorf("expected later throws to keep the start, got %v", m.StartedAt)
+	}
+
+	if err := repo.BustTurn(ctx, match.ID, a, 2); err != nil {
+		t.Fatalf("bust turn: %v", err)
+	}
+	got, err := repo.GetThrow(ctx, third.ID)
+	if err != nil || !got.Busted {
+		t.Errorf("expected the turn to be busted, got %+v, %v", got, err)
+	}
+	if got, _ := repo.GetThrow(ctx, first.ID); got.Busted {
+		t.Errorf("expected other turns not to be busted")
+	}
+
+	got.ThrowType = int(models.S1)
+	got.EndedTurn = true
+	updated, err := repo.UpdateThrow(ctx, got)
+	if err != nil {
+		t.Fatalf("update throw: %v", err)
+	}
+	if updated.ThrowType != int(models.S1) || !updated.EndedTurn || !updated.ThrownAt.Equal(third.ThrownAt) {
+		t.Errorf("unexpected updated throw %+v", updated)
+	}
+
+	last, err := repo.GetLastThrow(ctx, match.ID)
+	if err != nil || last.ID != third.ID {
+		t.Errorf("expected the last throw %d, got %+v, %v", third.ID, last, err)
+	}
+	if err := repo.DeleteThrow(ctx, third.ID); err != nil {
+		t.Fatalf("delete throw: %v", err)
+	}
+	throws, err := repo.GetThrows(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get throws: %v", err)
+	}
+	if len(throws) != 3 {
+		t.Fatalf("expected 3 throws, got %d", len(throws))
+	}
+	for i := 1; i < len(throws); i++ {
+		if throws[i-1].ID >= throws[i].ID {
+			t.Errorf("expected the throws in the order they were thrown, got %+v", throws)
+		}
+	}
+	if _, err := repo.GetThrow(ctx, third.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the throw to be deleted, got %v", err)
+	}
+}
+
+func testHistory(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	a, b := pids[0], pids[1]
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: a, ThrowType: int(models.S1), EndedTurn: true})
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: b, ThrowType: int(models.S2), EndedTurn: true})
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: a, ThrowType: int(models.S3)})
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: a, ThrowType: int(models.S4)})
+
+	last, err := repo.GetLastTurnHistory(ctx, match)
+	if err != nil {
+		t.Fatalf("last turn history: %v", err)
+	}
+	if h := last.History[a]; len(h) != 2 || h[0].Throw != models.S4 || h[1].Throw != models.S3 || h[0].TurnNumber != 2 {
+		t.Errorf("expected a's current turn latest first, got %+v", h)
+	}
+	if h := last.History[b]; len(h) != 1 || h[0].Throw != models.S2 {
+		t.Errorf("expected b's last turn, got %+v", h)
+	}
+
+	// the last turn only looks at the current leg
+	match.Leg = 1
+	last, err = repo.GetLastTurnHistory(ctx, match)
+	if err != nil {
+		t.Fatalf("last turn history: %v", err)
+	}
+	if len(last.History[a]) != 0 || len(last.History[b]) != 0 {
+		t.Errorf("expected no throws in a new leg, got %+v", last.History)
+	}
+
+	all, err := repo.GetHistory(ctx, match)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if h := all.History[a]; len(h) != 3 || h[2].Throw != models.S1 {
+		t.Errorf("expected all of a's throws latest first, got %+v", h)
+	}
+}
+
+func testWonAndReopened(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	a, b := pids[0], pids[1]
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+	createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: b, ThrowType: int(models.T20), EndedTurn: true, Busted: true})
+	winMatch(t, ctx, repo, match, a)
+
+	if _, err := repo.GetActiveMatch(ctx, match.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the match not to be active, got %v", err)
+	}
+	got, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if got.WonBy != a || got.FinishedAt == nil {
+		t.Errorf("expected a finished match won by a, got %+v", got)
+	}
+
+	statsA, _ := repo.GetPlayerStats(ctx, a)
+	statsB, _ := repo.GetPlayerStats(ctx, b)
+	if statsA.Matches != 1 || statsA.Wins != 1 || statsA.Throws != 1 || statsA.TotalScore != 40 {
+		t.Errorf("unexpected stats of the winner %+v", statsA)
+	}
+	if statsB.Matches != 1 || statsB.Wins != 0 || statsB.Throws != 1 || statsB.TotalScore != 0 {
+		t.Errorf("expected busted darts to score nothing, got %+v", statsB)
+	}
+	if statsA.Rating <= rating.Initial || statsB.Rating >= rating.Initial {
+		t.Errorf("expected the winner to gain rating, got %v and %v", statsA.Rating, statsB.Rating)
+	}
+	history, err := repo.GetRatingHistory(ctx, a)
+	if err != nil || len(history) != 1 || history[0].Mid != match.ID || history[0].Rating != statsA.Rating {
+		t.Errorf("expected one rating point, got %+v, %v", history, err)
+	}
+
+	finished, err := repo.GetFinishedMatches(ctx, time.Time{})
+	if err != nil || len(finished) != 1 {
+		t.Errorf("expected one finished match, got %d, %v", len(finished), err)
+	}
+	finished, err = repo.GetFinishedMatches(ctx, got.FinishedAt.Add(time.Hour))
+	if err != nil || len(finished) != 0 {
+		t.Errorf("expected no match finished within the window, got %d, %v", len(finished), err)
+	}
+
+	// a re-rated match does not count twice
+	if err := repo.WonMatch(ctx, match); err != nil {
+		t.Fatalf("won match: %v", err)
+	}
+	if again, _ := repo.GetPlayerStats(ctx, a); again.Rating != statsA.Rating || again.Wins != 1 {
+		t.Errorf("expected the same stats after re-rating, got %+v", again)
+	}
+	if err := repo.RecomputeRatings(ctx); err != nil {
+		t.Fatalf("recompute ratings: %v", err)
+	}
+	if again, _ := repo.GetPlayerStats(ctx, a); again.Rating != statsA.Rating {
+		t.Errorf("expected recomputing to give the same rating, got %v", again.Rating)
+	}
+
+	if err := repo.ReopenMatch(ctx, match.ID); err != nil {
+		t.Fatalf("reopen match: %v", err)
+	}
+	got, err = repo.GetActiveMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get active match: %v", err)
+	}
+	if got.WonBy != "" || got.FinishedAt != nil {
+		t.Errorf("expected the winner to be cleared, got %+v", got)
+	}
+	statsA, _ = repo.GetPlayerStats(ctx, a)
+	if statsA.Matches != 0 || statsA.Wins != 0 || statsA.Throws != 0 || statsA.Rating != rating.Initial {
+		t.Errorf("expected the stats to be rolled back, got %+v", statsA)
+	}
+	if history, _ := repo.GetRatingHistory(ctx, a); len(history) != 0 {
+		t.Errorf("expected no rating points, got %+v", history)
+	}
+}
+
+// testFinishedAtKeptOnEdit edits an early throw of a finished match and wins it again, like replaying an
+// edited match does, which must not move the match into a later leaderboard window.
+func testFinishedAtKeptOnEdit(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+	first := createThrow(t, ctx, repo, storage.ThrowRecord{Mid: match.ID, Pid: pids[0], ThrowType: int(models.S20)})
+	winMatch(t, ctx, repo, match, pids[0])
+	won, err := repo.GetMatch(ctx, match.ID)
+	if err != nil || won.FinishedAt == nil {
+		t.Fatalf("expected a finished match, got %+v, %v", won, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	edited := *first
+	edited.ThrowType = int(models.S19)
+	if _, err := repo.UpdateThrow(ctx, &edited); err != nil {
+		t.Fatalf("update throw: %v", err)
+	}
+	if err := repo.WonMatch(ctx, match); err != nil {
+		t.Fatalf("won match: %v", err)
+	}
+	got, err := repo.GetMatch(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get match: %v", err)
+	}
+	if got.FinishedAt == nil || !got.FinishedAt.Equal(*won.FinishedAt) {
+		t.Errorf("expected the match to keep finishing at %v, got %v", won.FinishedAt, got.FinishedAt)
+	}
+}
+
+func testDeleteMatch(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b")
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+	winMatch(t, ctx, repo, match, pids[1])
+
+	if err := repo.DeleteMatch(ctx, match.ID); err != nil {
+		t.Fatalf("delete match: %v", err)
+	}
+	if _, err := repo.GetMatch(ctx, match.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the match to be deleted, got %v", err)
+	}
+	if throws, err := repo.GetThrows(ctx, match.ID); err != nil || len(throws) != 0 {
+		t.Errorf("expected the throws to be deleted, got %d, %v", len(throws), err)
+	}
+	if mps, err := repo.GetAllMatchPlayers(ctx, match.ID); err != nil || len(mps) != 0 {
+		t.Errorf("expected the match players to be deleted, got %d, %v", len(mps), err)
+	}
+	stats, _ := repo.GetPlayerStats(ctx, pids[1])
+	if stats.Matches != 0 || stats.Wins != 0 || stats.Rating != rating.Initial {
+		t.Errorf("expected the stats to be reset, got %+v", stats)
+	}
+}
+
+func testSharedMatches(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "a", "b", "c")
+	a, b, c := pids[0], pids[1], pids[2]
+	ab := createMatch(t, ctx, repo, models.X01, a, b)
+	createMatch(t, ctx, repo, models.X01, a, c)
+
+	if matches, err := repo.GetPlayerMatches(ctx, a); err != nil || len(matches) != 2 {
+		t.Errorf("expected 2 matches of a, got %d, %v", len(matches), err)
+	}
+	matches, err := repo.GetSharedMatches(ctx, b, a)
+	if err != nil {
+		t.Fatalf("shared matches: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != ab.ID {
+		t.Errorf("expected only the match of a and b, got %+v", matches)
+	}
+	if matches, err := repo.GetSharedMatches(ctx, b, c); err != nil || len(matches) != 0 {
+		t.Errorf("expected no shared matches of b and c, got %d, %v", len(matches), err)
+	}
+}
+
+func testRunInTx(t *testing.T, ctx context.Context, repo storage.Repository) {
+	failed := errors.New("failed")
+	var pid string
+	err := repo.RunInTx(ctx, func(ctx context.Context, tx storage.Repository) error {
+		p, err := tx.CreatePlayer(ctx, "Alice")
+		if err != nil {
+			return err
+		}
+		pid = p.ID
+		// nested calls join the transaction
+		return tx.RunInTx(ctx, func(ctx context.Context, tx storage.Repository) error {
+			if _, err := tx.GetPlayer(ctx, pid); err != nil {
+				t.Errorf("expected the player to be visible inside the transaction: %v", err)
+			}
+			return failed
+		})
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if _, err := repo.GetPlayer(ctx, pid); err == nil {
+		t.Errorf("expected the player to be rolled back")
+	}
+	if _, err := repo.GetPlayerStats(ctx, pid); err == nil {
+		t.Errorf("expected the player stats to be rolled back")
+	}
+
+	err = repo.RunInTx(ctx, func(ctx context.Context, tx storage.Repository) error {
+		p, err := tx.CreatePlayer(ctx, "Bob")
+		if err != nil {
+			return err
+		}
+		pid = p.ID
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run in tx: %v", err)
+	}
+	if _, err := repo.GetPlayer(ctx, pid); err != nil {
+		t.Errorf("expected the player to be committed: %v", err)
+	}
+}
+
+// testRollbackInPlaceChanges rolls back changes to existing rows, which MemoryStore must not make to the
+// tables it shares with the data before the transaction.
+func testRollbackInPlaceChanges(t *testing.T, ctx context.Context, repo storage.Repository) {
+	pids := createPlayers(t, ctx, repo, "Alice", "Bob")
+	match := createMatch(t, ctx, repo, models.X01, pids...)
+	var throws []*storage.ThrowRecord
+	for _, throw := range []models.ThrowType{models.S20, models.S19} {
+		tr, err := repo.CreateThrow(ctx, storage.ThrowRecord{Mid: match.ID, Pid: pids[0], ThrowType: int(throw), Turn: 1})
+		if err != nil {
+			t.Fatalf("create throw: %v", err)
+		}
+		throws = append(throws, tr)
+	}
+
+	failed := errors.New("failed")
+	err := repo.RunInTx(ctx, func(ctx context.Context, tx storage.Repository) error {
+		if err := tx.BustTurn(ctx, match.ID, pids[0], 1); err != nil {
+			return err
+		}
+		edited := *throws[0]
+		edited.ThrowType = int(models.T20)
+		if _, err := tx.UpdateThrow(ctx, &edited); err != nil {
+			return err
+		}
+		if err := tx.DeleteThrow(ctx, throws[1].ID); err != nil {
+			return err
+		}
+		mp, err := tx.GetMatchPlayerModel(ctx, match.ID, pids[0])
+		if err != nil {
+			return err
+		}
+		mp.Score = 1
+		if _, err := tx.UpdateMatchPlayer(ctx, mp); err != nil {
+			return err
+		}
+		if _, err := tx.UpdatePlayer(ctx, pids[1], "Carol"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	got, err := repo.GetThrows(ctx, match.ID)
+	if err != nil {
+		t.Fatalf("get throws: %v", err)
+	}
+	if len(got) != 2 || got[0].ThrowType != int(models.S20) || got[0].Busted || got[1].Busted {
+		t.Errorf("expected the throws to be rolled back, got %+v %+v", got[0], got[len(got)-1])
+	}
+	if mp, err := repo.GetMatchPlayerModel(ctx, match.ID, pids[0]); err != nil || mp.Score != 301 {
+		t.Errorf("expected the match player to be rolled back, got %+v %v", mp, err)
+	}
+	if p, err := repo.GetPlayer(ctx, pids[1]); err != nil || p.Name != "Bob" {
+		t.Errorf("expected the player to be rolled back, got %+v %v", p, err)
+	}
+}
+
+func testNotFound(t *testing.T, ctx context.Context, repo storage.Repository) {
+	for name, err := range map[string]error{
+		"player":       second(repo.GetPlayer(ctx, "missing")),
+		"match":        second(repo.GetMatch(ctx, "missing")),
+		"active match": second(repo.GetActiveMatch(ctx, "missing")),
+		"match player": second(repo.GetMatchPlayerModel(ctx, "missing", "missing")),
+		"throw":        second(repo.GetThrow(ctx, 42)),
+		"last throw":   second(repo.GetLastThrow(ctx, "missing")),
+		"player stats": second(repo.GetPlayerStats(ctx, "missing")),
+	} {
+		if !errors.Is(err, storage.ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected a missing %s to fail with storage.ErrNotFound, got %v", name, err)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}